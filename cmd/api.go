@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type APIConfig struct {
+	Enabled bool   `json:"enabled"`
+	Addr    string `json:"addr"`
+	// bearer token required by the endpoints that change something,
+	// they are disabled while it is empty
+	Token string `json:"token"`
+}
+
+// when the app was started, reported by the health endpoint
+var appStartTime = time.Now()
+
+type statusResponse struct {
+	Time         time.Time      `json:"time"`
+	SignState    string         `json:"signState"`
+	SpeedLimit   int            `json:"speedLimit"`
+	ActiveCamera int            `json:"activeCamera"`
+	Cameras      []StreamStatus `json:"cameras"`
+	Override     *Override      `json:"override"`
+}
+
+type cameraResponse struct {
+	CameraDevice
+	Streaming bool          `json:"streaming"`
+	Status    *StreamStatus `json:"status,omitempty"`
+}
+
+type healthResponse struct {
+	Status       string   `json:"status"`
+	Uptime       string   `json:"uptime"`
+	Models       []string `json:"models"`
+	ModelsWanted int      `json:"modelsConfigured"`
+	EventStore   bool     `json:"eventStore"`
+	Cameras      int      `json:"cameras"`
+	Streaming    int      `json:"streaming"`
+	StaleCameras []int    `json:"staleCameras"`
+}
+
+// cameras without a processed frame for longer than this are unhealthy
+const staleFrameAge = 5 * time.Second
+
+/**
+ * Start the HTTP API in the background when it is enabled.
+ * @param *app
+ */
+func StartAPIServer(app *App) *http.Server {
+	config := app.Config.API
+	if !config.Enabled {
+		return nil
+	}
+
+	server := &http.Server{
+		Addr:              config.Addr,
+		Handler:           newAPIMux(app),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		fmt.Printf("HTTP API listening on %s\n", config.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Error running HTTP API: %v\n", err)
+		}
+	}()
+	return server
+}
+
+/**
+ * Routes of the HTTP API.
+ * @param *app
+ * @return *http.ServeMux
+ */
+func newAPIMux(app *App) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", app.handleStatus)
+	mux.HandleFunc("GET /api/health", app.handleHealth)
+	mux.HandleFunc("GET /api/config", app.handleConfig)
+	mux.HandleFunc("GET /api/cameras", app.handleCameras)
+	mux.HandleFunc("GET /api/cameras/{id}/detections", app.handleDetections)
+	mux.HandleFunc("POST /api/cameras/{id}/snapshot", app.requireToken(app.handleSnapshot))
+	mux.HandleFunc("GET /api/cameras/{id}/stream.mjpg", app.handleMJPEG)
+	mux.HandleFunc("GET /api/cameras/{id}/raw.mjpg", app.handleRawMJPEG)
+	mux.HandleFunc("GET /api/events", app.handleEvents)
+	mux.HandleFunc("GET /api/override", app.handleGetOverride)
+	mux.HandleFunc("POST /api/override", app.requireToken(app.handleSetOverride))
+	mux.HandleFunc("DELETE /api/override", app.requireToken(app.handleClearOverride))
+	mux.Handle("GET /api/ws", app.WSHub.Server(app))
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+/**
+ * Only pass requests that carry the configured token
+ * in an "Authorization: Bearer <token>" header.
+ * @param next http.HandlerFunc
+ * @return http.HandlerFunc
+ */
+func (app *App) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := app.Config.API.Token
+		if token == "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("write endpoints are disabled, set api.token to enable them"))
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid API token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Error writing API response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+/**
+ * Stream of the camera id in the request path.
+ * @return *CameraStream, error written to the response when not found
+ */
+func (app *App) requestStream(w http.ResponseWriter, r *http.Request) (*CameraStream, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid camera id %q", r.PathValue("id")))
+		return nil, false
+	}
+
+	app.StreamsMu.Lock()
+	stream, ok := app.Streams[id]
+	app.StreamsMu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("camera %d is not streaming", id))
+		return nil, false
+	}
+	return stream, true
+}
+
+func (app *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	state, limit := app.currentSign()
+	response := statusResponse{
+		Time:         time.Now(),
+		SignState:    state.String(),
+		SpeedLimit:   limit,
+		ActiveCamera: app.activeCamera(),
+		Cameras:      []StreamStatus{},
+		Override:     app.activeOverride(),
+	}
+	for _, stream := range app.activeStreams() {
+		response.Cameras = append(response.Cameras, stream.Status())
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (app *App) handleHealth(w http.ResponseWriter, r *http.Request) {
+	app.ModelsMu.RLock()
+	models := make([]string, len(app.Models))
+	for i, model := range app.Models {
+		models[i] = model.Config.Name
+	}
+	app.ModelsMu.RUnlock()
+	app.ConfigMu.RLock()
+	wanted := len(app.Config.Models)
+	app.ConfigMu.RUnlock()
+
+	response := healthResponse{
+		Status:       "ok",
+		Uptime:       time.Since(appStartTime).Round(time.Second).String(),
+		Models:       models,
+		ModelsWanted: wanted,
+		EventStore:   app.EventStore != nil,
+		Cameras:      len(app.CameraDevices),
+		StaleCameras: []int{},
+	}
+
+	now := time.Now()
+	for _, stream := range app.activeStreams() {
+		response.Streaming++
+		if status := stream.Status(); now.Sub(status.LastFrame) > staleFrameAge {
+			response.StaleCameras = append(response.StaleCameras, stream.Device.ID)
+		}
+	}
+
+	status := http.StatusOK
+	if len(models) < wanted || len(response.StaleCameras) > 0 {
+		response.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, response)
+}
+
+func (app *App) handleConfig(w http.ResponseWriter, r *http.Request) {
+	app.ConfigMu.RLock()
+	config := app.Config.redacted()
+	app.ConfigMu.RUnlock()
+	writeJSON(w, http.StatusOK, config)
+}
+
+func (app *App) handleCameras(w http.ResponseWriter, r *http.Request) {
+	cameras := []cameraResponse{}
+	for _, device := range app.CameraDevices {
+		camera := cameraResponse{CameraDevice: device}
+
+		app.StreamsMu.Lock()
+		stream, ok := app.Streams[device.ID]
+		app.StreamsMu.Unlock()
+		if ok {
+			status := stream.Status()
+			status.Detections = nil
+			camera.Streaming = true
+			camera.Status = &status
+		}
+		cameras = append(cameras, camera)
+	}
+	writeJSON(w, http.StatusOK, cameras)
+}
+
+func (app *App) handleDetections(w http.ResponseWriter, r *http.Request) {
+	stream, ok := app.requestStream(w, r)
+	if !ok {
+		return
+	}
+	status := stream.Status()
+	detections := status.Detections
+	if detections == nil {
+		detections = []Detection{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"cameraId":   stream.Device.ID,
+		"time":       status.LastFrame,
+		"detections": detections,
+	})
+}
+
+func (app *App) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	stream, ok := app.requestStream(w, r)
+	if !ok {
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	if reason == "" {
+		reason = "api"
+	}
+	snapshot, err := stream.takeSnapshot(reason)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+/**
+ * Event history from the event store. Query parameters: type (comma
+ * separated), camera, from and to (RFC 3339) and limit.
+ */
+func (app *App) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if app.EventStore == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("event store not open"))
+		return
+	}
+
+	params := r.URL.Query()
+	query := EventQuery{Limit: 1000}
+	if types := params.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			query.Types = append(query.Types, EventType(strings.TrimSpace(t)))
+		}
+	}
+	if camera := params.Get("camera"); camera != "" {
+		id, err := strconv.Atoi(camera)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid camera %q", camera))
+			return
+		}
+		query.CameraID = &id
+	}
+	for name, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid %s, expected RFC 3339: %v", name, err))
+				return
+			}
+			*target = parsed
+		}
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", limit))
+			return
+		}
+		query.Limit = n
+	}
+
+	events, err := app.EventStore.Query(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if events == nil {
+		events = []Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+type overrideRequest struct {
+	State      string  `json:"state"`
+	SpeedLimit int     `json:"speedLimit"`
+	Source     string  `json:"source"`
+	Reason     string  `json:"reason"`
+	Minutes    float64 `json:"durationMinutes"`
+}
+
+func (app *App) handleGetOverride(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"override": app.activeOverride()})
+}
+
+func (app *App) handleSetOverride(w http.ResponseWriter, r *http.Request) {
+	var request overrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid override: %v", err))
+		return
+	}
+	override := Override{State: request.State, SpeedLimit: request.SpeedLimit, Source: request.Source, Reason: request.Reason}
+	applied, err := setOverride(app, override, time.Duration(request.Minutes*float64(time.Minute)))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, applied)
+}
+
+func (app *App) handleClearOverride(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	if source == "" {
+		source = "api"
+	}
+	if err := clearOverride(app, source); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		header     string
+		wantStatus int
+	}{
+		{"no token configured", "", "Bearer secret", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "Basic secret", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusNoContent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := &App{Config: &Config{API: APIConfig{Token: test.token}}}
+			handler := app.requireToken(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+			r := httptest.NewRequest("DELETE", "/api/override", nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// zone kind used to draw calibration points, never used for filtering
+const ZoneCalibration ZoneKind = "calibration"
+
+/**
+ * Ground plane calibration of a camera. The four image points are
+ * marked on the road in the Debug tab and WorldPoints holds their
+ * positions on the road plane in meters.
+ */
+type Calibration struct {
+	ImagePoints []Point `json:"imagePoints"`
+	WorldPoints []Point `json:"worldPoints"`
+}
+
+/**
+ * World points of a rectangle on the road, in the same order the
+ * image points are marked: near left, near right, far right, far left.
+ * @param width, length float32 meters across and along the road
+ * @return []Point
+ */
+func rectangleWorldPoints(width, length float32) []Point {
+	return []Point{{0, 0}, {width, 0}, {width, length}, {0, length}}
+}
+
+/**
+ * Row major 3x3 projective transform.
+ */
+type Homography [9]float64
+
+func (c *Calibration) Homography() (Homography, error) {
+	return computeHomography(c.ImagePoints, c.WorldPoints)
+}
+
+/**
+ * Map a point through the homography.
+ * @param p Point
+ * @return x, y float64
+ */
+func (h Homography) Apply(p Point) (float64, float64) {
+	x, y := float64(p.X), float64(p.Y)
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+/**
+ * Solve the homography mapping four src points onto dst points
+ * with h33 fixed to 1 (direct linear transform).
+ * @param src, dst []Point
+ * @return Homography, error
+ */
+func computeHomography(src, dst []Point) (Homography, error) {
+	if len(src) != 4 || len(dst) != 4 {
+		return Homography{}, fmt.Errorf("homography needs 4 point pairs, got %d and %d", len(src), len(dst))
+	}
+
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := float64(src[i].X), float64(src[i].Y)
+		u, v := float64(dst[i].X), float64(dst[i].Y)
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// gaussian elimination with partial pivoting on the augmented matrix
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return Homography{}, fmt.Errorf("calibration points are degenerate, three of them may be on one line")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			factor := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+
+	var h Homography
+	for i := 0; i < 8; i++ {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
+
+/**
+ * Homography of a camera.
+ * @param CameraDevice
+ * @return Homography, bool false when the camera is not calibrated
+ */
+func (app *App) cameraHomography(device CameraDevice) (Homography, bool) {
+	camera, ok := app.cameraConfig(device)
+	if !ok || camera.Calibration == nil {
+		return Homography{}, false
+	}
+	h, err := camera.Calibration.Homography()
+	if err != nil {
+		return Homography{}, false
+	}
+	return h, true
+}
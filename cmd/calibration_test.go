@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeHomography(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []Point
+		dst  []Point
+	}{
+		{
+			name: "identity",
+			src:  []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+			dst:  []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+		},
+		{
+			name: "scale and offset",
+			src:  []Point{{0.2, 0.2}, {0.6, 0.2}, {0.6, 0.8}, {0.2, 0.8}},
+			dst:  rectangleWorldPoints(3.5, 12),
+		},
+		{
+			name: "road in perspective",
+			src:  []Point{{0.1, 0.9}, {0.9, 0.9}, {0.6, 0.4}, {0.4, 0.4}},
+			dst:  rectangleWorldPoints(7, 40),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := computeHomography(test.src, test.dst)
+			if err != nil {
+				t.Fatalf("computeHomography() error: %v", err)
+			}
+			for i := range test.src {
+				x, y := h.Apply(test.src[i])
+				if math.Abs(x-float64(test.dst[i].X)) > 1e-4 || math.Abs(y-float64(test.dst[i].Y)) > 1e-4 {
+					t.Errorf("point %d maps to (%.4f, %.4f), want %v", i, x, y, test.dst[i])
+				}
+			}
+		})
+	}
+}
+
+func TestComputeHomographyPerspective(t *testing.T) {
+	// the far edge of the rectangle is half as wide in the image, so the
+	// near half of the image covers only a third of the rectangle
+	src := []Point{{0.1, 0.9}, {0.9, 0.9}, {0.7, 0.3}, {0.3, 0.3}}
+	h, err := computeHomography(src, rectangleWorldPoints(10, 10))
+	if err != nil {
+		t.Fatalf("computeHomography() error: %v", err)
+	}
+	_, y := h.Apply(Point{0.5, 0.6})
+	if math.Abs(y-10.0/3) > 1e-3 {
+		t.Errorf("image midpoint maps to %.3f m along the road, want %.3f", y, 10.0/3)
+	}
+}
+
+func TestComputeHomographyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []Point
+		dst  []Point
+	}{
+		{"too few points", []Point{{0, 0}, {1, 0}, {1, 1}}, rectangleWorldPoints(1, 1)[:3]},
+		{"point counts differ", []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, rectangleWorldPoints(1, 1)[:3]},
+		{"collinear points", []Point{{0, 0}, {0.5, 0.5}, {1, 1}, {0.2, 0.2}}, rectangleWorldPoints(1, 1)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := computeHomography(test.src, test.dst); err == nil {
+				t.Errorf("computeHomography() accepted %v", test.src)
+			}
+		})
+	}
+}
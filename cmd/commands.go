@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/**
+ * Remote command received over MQTT, e.g.
+ * {"command": "snapshot", "cameraId": 0, "source": "tmc"} or
+ * {"command": "override", "speedLimit": 60, "source": "tmc", "reason": "roadworks", "durationMinutes": 120}
+ */
+type Command struct {
+	Command  string `json:"command"`
+	CameraID int    `json:"cameraId"`
+	Source   string `json:"source"`
+
+	// override
+	State      string  `json:"state"`
+	SpeedLimit int     `json:"speedLimit"`
+	Reason     string  `json:"reason"`
+	Minutes    float64 `json:"durationMinutes"`
+}
+
+/**
+ * Decode and run a remote command.
+ * @param *app, payload []byte json encoded Command
+ * @return error
+ */
+func (app *App) handleCommand(payload []byte) error {
+	var command Command
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return fmt.Errorf("invalid command: %v", err)
+	}
+
+	switch command.Command {
+	case "snapshot":
+		app.StreamsMu.Lock()
+		stream, ok := app.Streams[command.CameraID]
+		app.StreamsMu.Unlock()
+		if !ok {
+			return fmt.Errorf("camera %d is not streaming", command.CameraID)
+		}
+		_, err := stream.takeSnapshot(fmt.Sprintf("remote (%s)", command.Source))
+		return err
+	case "reload_models":
+		return app.reloadModels()
+	case "override":
+		override := Override{State: command.State, SpeedLimit: command.SpeedLimit, Source: command.Source, Reason: command.Reason}
+		_, err := setOverride(app, override, time.Duration(command.Minutes*float64(time.Minute)))
+		return err
+	case "clear_override":
+		return clearOverride(app, command.Source)
+	}
+	return fmt.Errorf("unknown command %q", command.Command)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	ConfigPath = "./config.json"
+)
+
+/**
+ * Runtime configuration loaded from ConfigPath.
+ * Missing fields keep the values from DefaultConfig.
+ */
+type Config struct {
+	EventDB        string               `json:"eventDb"`
+	Models         []ModelConfig        `json:"models"`
+	Cameras        []CameraConfig       `json:"cameras"`
+	VehicleClasses []string             `json:"vehicleClasses"` // model labels of vehicles, e.g. COCO car, truck, bus
+	StoppedVehicle StoppedVehicleConfig `json:"stoppedVehicle"`
+	SpeedLimits    SpeedLimitConfig     `json:"speedLimits"`
+	WrongWay       WrongWayConfig       `json:"wrongWay"`
+	Hazards        HazardConfig         `json:"hazards"`
+	Recording      RecordingConfig      `json:"recording"`
+	Snapshots      SnapshotConfig       `json:"snapshots"`
+	Privacy        PrivacyConfig        `json:"privacy"`
+	API            APIConfig            `json:"api"`
+	MJPEG          MJPEGConfig          `json:"mjpeg"`
+	WebSocket      WebSocketConfig      `json:"webSocket"`
+	MQTT           MQTTConfig           `json:"mqtt"`
+	SignDisplay    SignDisplayConfig    `json:"signDisplay"`
+}
+
+/**
+ * Settings of a single camera, matched by device path or name.
+ */
+type CameraConfig struct {
+	Device string         `json:"device"`
+	Zones  []Zone         `json:"zones"`
+	Lines  []CountingLine `json:"lines"`
+
+	Calibration *Calibration  `json:"calibration,omitempty"`
+	Tiling      *TilingConfig `json:"tiling,omitempty"`
+	// normalized polygons that are always masked, e.g. house windows
+	PrivacyMasks [][]Point `json:"privacyMasks,omitempty"`
+}
+
+type StoppedVehicleConfig struct {
+	// seconds a vehicle has to stand still before it is reported
+	MinDuration float64 `json:"minDurationSeconds"`
+	// allowed drift of the box bottom center, fraction of the frame size
+	MaxMovement float32 `json:"maxMovement"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		EventDB: "./events.db",
+		Models: []ModelConfig{
+			{
+				Name:          "accident",
+				Path:          ModelPath,
+				Labels:        []string{"accident", "vehicle"},
+				ConfThreshold: 0.25,
+				IoUThreshold:  0.45,
+			},
+		},
+		VehicleClasses: []string{"vehicle", "car", "truck", "bus", "motorcycle"},
+		StoppedVehicle: StoppedVehicleConfig{
+			MinDuration: 10,
+			MaxMovement: 0.02,
+		},
+		WrongWay: WrongWayConfig{
+			MinFrames:   5,
+			MinMovement: 0.03,
+			MaxCosine:   -0.5,
+			HoldSeconds: 60,
+		},
+		Hazards: HazardConfig{
+			PedestrianClasses: []string{"person"},
+			AnimalClasses:     []string{"bird", "cat", "dog", "horse", "sheep", "cow", "bear"},
+			MinDuration:       1,
+			HoldSeconds:       10,
+		},
+		Recording: RecordingConfig{
+			Enabled:       true,
+			Dir:           "./recordings",
+			PreRoll:       10,
+			PostRoll:      10,
+			FPS:           10,
+			TriggerStates: []string{SignStateAccident.String(), SignStateWrongWay.String(), SignStateStoppedVehicle.String()},
+			MaxDiskMB:     2048,
+			RetentionDays: 30,
+		},
+		API: APIConfig{
+			Enabled: true,
+			Addr:    "127.0.0.1:8080",
+		},
+		MQTT: MQTTConfig{
+			Broker:       "tcp://localhost:1883",
+			ClientID:     "smartsign",
+			TopicPrefix:  "smartsign",
+			QoS:          1,
+			Heartbeat:    30,
+			EmbeddedAddr: "127.0.0.1:1883",
+		},
+		WebSocket: WebSocketConfig{
+			SummaryRate:  2,
+			ClientBuffer: 32,
+		},
+		MJPEG: MJPEGConfig{
+			Quality: 75,
+			MaxFPS:  10,
+		},
+		Privacy: PrivacyConfig{
+			Enabled: true,
+			Mode:    PrivacyBlur,
+			Classes: []string{"face", "license_plate"},
+			Padding: 0.1,
+		},
+		Snapshots: SnapshotConfig{
+			Dir:           "./snapshots",
+			OnStateChange: true,
+		},
+		SignDisplay: SignDisplayConfig{
+			ExitGesture:     true,
+			PreventBlanking: true,
+		},
+		SpeedLimits: SpeedLimitConfig{
+			Default: 100,
+			Rules: []SpeedRule{
+				{BelowAverage: 40, Limit: 50},
+				{BelowAverage: 20, Limit: 30},
+			},
+			Hysteresis: 10,
+			MinHold:    60,
+		},
+	}
+}
+
+/**
+ * Load config from json file on top of the defaults.
+ * A missing file is not an error, defaults are used instead.
+ * @param path string
+ * @return *Config, error
+ */
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return DefaultConfig(), fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+/**
+ * Write config as indented json.
+ * @param path string, *Config
+ * @return error
+ */
+func SaveConfig(path string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write config %s: %v", path, err)
+	}
+	return nil
+}
+
+// shown instead of secrets by the API
+const redactedSecret = "********"
+
+/**
+ * Copy of the config that is safe to hand out, secrets that are
+ * set are replaced by redactedSecret.
+ * @return Config
+ */
+func (c *Config) redacted() Config {
+	out := *c
+	if out.MQTT.Password != "" {
+		out.MQTT.Password = redactedSecret
+	}
+	if out.API.Token != "" {
+		out.API.Token = redactedSecret
+	}
+	return out
+}
+
+/**
+ * Find the config of a camera device.
+ * @param CameraDevice
+ * @return *CameraConfig, nil when the camera is not configured
+ */
+func (c *Config) CameraConfig(device CameraDevice) *CameraConfig {
+	for i := range c.Cameras {
+		if c.Cameras[i].Device == device.Path || c.Cameras[i].Device == device.Name {
+			return &c.Cameras[i]
+		}
+	}
+	return nil
+}
+
+/**
+ * Like CameraConfig, but adds an empty entry keyed by the
+ * device path when the camera is not configured yet.
+ * @param CameraDevice
+ * @return *CameraConfig
+ */
+func (c *Config) EnsureCameraConfig(device CameraDevice) *CameraConfig {
+	if camera := c.CameraConfig(device); camera != nil {
+		return camera
+	}
+	c.Cameras = append(c.Cameras, CameraConfig{Device: device.Path})
+	return &c.Cameras[len(c.Cameras)-1]
+}
+
+/**
+ * Copy of the config of a camera device, safe to use while
+ * the UI edits the camera configs.
+ * @param CameraDevice
+ * @return CameraConfig, bool false when the camera is not configured
+ */
+func (app *App) cameraConfig(device CameraDevice) (CameraConfig, bool) {
+	app.ConfigMu.RLock()
+	defer app.ConfigMu.RUnlock()
+	if camera := app.Config.CameraConfig(device); camera != nil {
+		return *camera, true
+	}
+	return CameraConfig{}, false
+}
+
+/**
+ * Change the config of a camera device, adding it when needed.
+ * @param CameraDevice, update func(*CameraConfig)
+ */
+func (app *App) updateCameraConfig(device CameraDevice, update func(*CameraConfig)) {
+	app.ConfigMu.Lock()
+	defer app.ConfigMu.Unlock()
+	update(app.Config.EnsureCameraConfig(device))
+}
+
+/**
+ * Write the config of the app to ConfigPath.
+ * @return error
+ */
+func (app *App) saveConfig() error {
+	app.ConfigMu.RLock()
+	defer app.ConfigMu.RUnlock()
+	return SaveConfig(ConfigPath, app.Config)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
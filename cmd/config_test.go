@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestConfigRedacted(t *testing.T) {
+	config := &Config{
+		MQTT: MQTTConfig{Username: "sign", Password: "secret"},
+		API:  APIConfig{Token: "token"},
+	}
+
+	out := config.redacted()
+	if out.MQTT.Password != redactedSecret {
+		t.Errorf("password = %q, want it redacted", out.MQTT.Password)
+	}
+	if out.API.Token != redactedSecret {
+		t.Errorf("API token = %q, want it redacted", out.API.Token)
+	}
+	if out.MQTT.Username != "sign" {
+		t.Errorf("username = %q, want it kept", out.MQTT.Username)
+	}
+	if config.MQTT.Password != "secret" {
+		t.Errorf("redacted() changed the original config")
+	}
+
+	if empty := (&Config{}).redacted(); empty.MQTT.Password != "" {
+		t.Errorf("unset password shown as %q", empty.MQTT.Password)
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	ExportDir = "./exports"
+
+	DirectionForward  = "forward"
+	DirectionBackward = "backward"
+
+	// per minute bins kept for export
+	maxCountBins = 24 * 60
+)
+
+// windows the flow statistics are reported for
+var countIntervals = []time.Duration{1 * time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+/**
+ * Virtual counting line in normalized frame coordinates.
+ * Tracks crossing from the left to the right side of the
+ * A -> B vector are counted as forward.
+ */
+type CountingLine struct {
+	Name string `json:"name"`
+	A    Point  `json:"a"`
+	B    Point  `json:"b"`
+}
+
+type countKey struct {
+	Line      string
+	Lane      string
+	Direction string
+}
+
+/**
+ * Vehicle counts of one line, lane and direction.
+ * Counts and Rates follow countIntervals, rates are vehicles per hour.
+ */
+type FlowStat struct {
+	Line      string
+	Lane      string
+	Direction string
+	Total     int
+	Counts    []int
+	Rates     []float64
+}
+
+/**
+ * Counts tracked vehicles crossing the counting lines of one camera.
+ */
+type LineCounter struct {
+	VehicleClasses []string
+
+	mu        sync.Mutex                     // Stats and ExportCSV are called from the UI and API
+	crossings map[countKey][]time.Time       // kept for the longest interval
+	totals    map[countKey]int               // since start
+	bins      map[time.Time]map[countKey]int // per minute, for export
+	last      map[int]trackAnchor            // previous anchor per track
+}
+
+type trackAnchor struct {
+	point Point
+	time  time.Time
+}
+
+func NewLineCounter(vehicleClasses []string) *LineCounter {
+	return &LineCounter{
+		VehicleClasses: vehicleClasses,
+		crossings:      make(map[countKey][]time.Time),
+		totals:         make(map[countKey]int),
+		bins:           make(map[time.Time]map[countKey]int),
+		last:           make(map[int]trackAnchor),
+	}
+}
+
+/**
+ * Check the movement of every vehicle track since the previous
+ * frame against the counting lines.
+ * @param lines []CountingLine, tracks []*Track, now time.Time
+ */
+func (c *LineCounter) Update(lines []CountingLine, tracks []*Track, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, track := range tracks {
+		if !containsString(c.VehicleClasses, track.ClassName) {
+			continue
+		}
+		anchor := normalizePoint(track.BBox.Anchor())
+		prev, ok := c.last[track.ID]
+		c.last[track.ID] = trackAnchor{point: anchor, time: now}
+		if !ok {
+			continue
+		}
+
+		for _, line := range lines {
+			if !segmentsIntersect(prev.point, anchor, line.A, line.B) {
+				continue
+			}
+			direction := DirectionForward
+			if cross(line.A, line.B, anchor) < 0 {
+				direction = DirectionBackward
+			}
+			c.add(countKey{Line: line.Name, Lane: track.Lane, Direction: direction}, now)
+		}
+	}
+
+	c.prune(now)
+}
+
+func (c *LineCounter) add(key countKey, now time.Time) {
+	c.crossings[key] = append(c.crossings[key], now)
+	c.totals[key]++
+
+	minute := now.Truncate(time.Minute)
+	if c.bins[minute] == nil {
+		c.bins[minute] = make(map[countKey]int)
+	}
+	c.bins[minute][key]++
+}
+
+func (c *LineCounter) prune(now time.Time) {
+	oldest := now.Add(-countIntervals[len(countIntervals)-1])
+	for key, times := range c.crossings {
+		i := 0
+		for i < len(times) && times[i].Before(oldest) {
+			i++
+		}
+		c.crossings[key] = times[i:]
+	}
+
+	// anchors of tracks that have been lost for a while
+	for id, anchor := range c.last {
+		if now.Sub(anchor.time) > time.Minute {
+			delete(c.last, id)
+		}
+	}
+
+	oldestBin := now.Truncate(time.Minute).Add(-maxCountBins * time.Minute)
+	for minute := range c.bins {
+		if minute.Before(oldestBin) {
+			delete(c.bins, minute)
+		}
+	}
+}
+
+/**
+ * Counts and flow rates per line, lane and direction.
+ * @param now time.Time
+ * @return []FlowStat sorted by line, lane and direction
+ */
+func (c *LineCounter) Stats(now time.Time) []FlowStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var stats []FlowStat
+	for key, total := range c.totals {
+		stat := FlowStat{
+			Line:      key.Line,
+			Lane:      key.Lane,
+			Direction: key.Direction,
+			Total:     total,
+		}
+		for _, interval := range countIntervals {
+			count := 0
+			for _, t := range c.crossings[key] {
+				if now.Sub(t) <= interval {
+					count++
+				}
+			}
+			stat.Counts = append(stat.Counts, count)
+			stat.Rates = append(stat.Rates, float64(count)*float64(time.Hour)/float64(interval))
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Line != stats[j].Line {
+			return stats[i].Line < stats[j].Line
+		}
+		if stats[i].Lane != stats[j].Lane {
+			return stats[i].Lane < stats[j].Lane
+		}
+		return stats[i].Direction < stats[j].Direction
+	})
+	return stats
+}
+
+/**
+ * Write the per minute counts into a csv file under ExportDir.
+ * @param camera string used in the file name
+ * @return path string, error
+ */
+func (c *LineCounter) ExportCSV(camera string) (string, error) {
+	bins := c.snapshotBins()
+
+	if err := os.MkdirAll(ExportDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export dir: %v", err)
+	}
+
+	name := fmt.Sprintf("counts_%s_%s.csv", sanitizeFileName(camera), time.Now().Format("20060102_150405"))
+	path := filepath.Join(ExportDir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	minutes := make([]time.Time, 0, len(bins))
+	for minute := range bins {
+		minutes = append(minutes, minute)
+	}
+	sort.Slice(minutes, func(i, j int) bool { return minutes[i].Before(minutes[j]) })
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"minute", "line", "lane", "direction", "count"})
+	for _, minute := range minutes {
+		for key, count := range bins[minute] {
+			w.Write([]string{minute.Format(time.RFC3339), key.Line, key.Lane, key.Direction, strconv.Itoa(count)})
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, nil
+}
+
+/**
+ * Copy of the per minute counts, so the file is written
+ * without blocking the detection loop.
+ * @return map[time.Time]map[countKey]int
+ */
+func (c *LineCounter) snapshotBins() map[time.Time]map[countKey]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bins := make(map[time.Time]map[countKey]int, len(c.bins))
+	for minute, counts := range c.bins {
+		bins[minute] = make(map[countKey]int, len(counts))
+		for key, count := range counts {
+			bins[minute][key] = count
+		}
+	}
+	return bins
+}
+
+// z component of (b - a) x (p - a), positive when p is right of a -> b in image coordinates
+func cross(a, b, p Point) float32 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+func segmentsIntersect(p1, p2, q1, q2 Point) bool {
+	d1 := cross(q1, q2, p1)
+	d2 := cross(q1, q2, p2)
+	d3 := cross(p1, p2, q1)
+	d4 := cross(p1, p2, q2)
+	return ((d1 > 0 && d2 <= 0) || (d1 <= 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+func sanitizeFileName(name string) string {
+	out := []rune(name)
+	for i, r := range out {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+/**
+ * Draw counting lines with an arrow pointing to the forward side.
+ * @param *gocv.Mat, lines []CountingLine
+ */
+func drawCountingLines(mat *gocv.Mat, lines []CountingLine) {
+	width := float32(mat.Cols())
+	height := float32(mat.Rows())
+	lineColor := color.RGBA{255, 255, 0, 255}
+
+	for _, line := range lines {
+		a := image.Pt(int(line.A.X*width), int(line.A.Y*height))
+		b := image.Pt(int(line.B.X*width), int(line.B.Y*height))
+		gocv.Line(mat, a, b, lineColor, 2)
+
+		// normal of a -> b towards the forward side
+		mid := image.Pt((a.X+b.X)/2, (a.Y+b.Y)/2)
+		tip := image.Pt(mid.X-(b.Y-a.Y)/4, mid.Y+(b.X-a.X)/4)
+		gocv.ArrowedLine(mat, mid, tip, lineColor, 2)
+
+		gocv.PutText(mat, line.Name, a, gocv.FontHersheySimplex, 0.5, lineColor, 1)
+	}
+}
+
+/**
+ * Counting lines configured for a camera.
+ * @param CameraDevice
+ * @return []CountingLine
+ */
+func (app *App) cameraLines(device CameraDevice) []CountingLine {
+	if camera, ok := app.cameraConfig(device); ok {
+		return camera.Lines
+	}
+	return nil
+}
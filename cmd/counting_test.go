@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSegmentsIntersect(t *testing.T) {
+	tests := []struct {
+		name           string
+		p1, p2, q1, q2 Point
+		want           bool
+	}{
+		{"crossing", Point{0.5, 0.2}, Point{0.5, 0.8}, Point{0.1, 0.5}, Point{0.9, 0.5}, true},
+		{"crossing backwards", Point{0.5, 0.8}, Point{0.5, 0.2}, Point{0.1, 0.5}, Point{0.9, 0.5}, true},
+		{"short of the line", Point{0.5, 0.2}, Point{0.5, 0.4}, Point{0.1, 0.5}, Point{0.9, 0.5}, false},
+		{"past the line end", Point{0.95, 0.2}, Point{0.95, 0.8}, Point{0.1, 0.5}, Point{0.9, 0.5}, false},
+		{"parallel", Point{0.1, 0.4}, Point{0.9, 0.4}, Point{0.1, 0.5}, Point{0.9, 0.5}, false},
+		{"collinear", Point{0.2, 0.5}, Point{0.6, 0.5}, Point{0.1, 0.5}, Point{0.9, 0.5}, false},
+		// an anchor resting on the line counts once, when it leaves
+		{"ending on the line", Point{0.5, 0.2}, Point{0.5, 0.5}, Point{0.1, 0.5}, Point{0.9, 0.5}, false},
+		{"starting on the line", Point{0.5, 0.5}, Point{0.5, 0.8}, Point{0.1, 0.5}, Point{0.9, 0.5}, true},
+		{"diagonal", Point{0.1, 0.1}, Point{0.9, 0.9}, Point{0.9, 0.1}, Point{0.1, 0.9}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := segmentsIntersect(test.p1, test.p2, test.q1, test.q2); got != test.want {
+				t.Errorf("segmentsIntersect() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLineCounterCountsVehicleClasses(t *testing.T) {
+	counter := NewLineCounter([]string{"car", "truck"})
+	lines := []CountingLine{{Name: "gate", A: Point{0, 0.5}, B: Point{1, 0.5}}}
+	now := time.Now()
+
+	// anchors move from above to below the line, in detection space
+	tracks := func(y float32) []*Track {
+		var out []*Track
+		for i, class := range []string{"car", "truck", "person"} {
+			x := float32(100 + i*150)
+			out = append(out, &Track{ID: i + 1, ClassName: class, Lane: "1", BBox: BoundingBox{XMin: x, YMin: y - 40, XMax: x + 50, YMax: y}})
+		}
+		return out
+	}
+	counter.Update(lines, tracks(InputSize*0.4), now)
+	counter.Update(lines, tracks(InputSize*0.6), now.Add(time.Second))
+
+	stats := counter.Stats(now.Add(time.Second))
+	if len(stats) != 1 {
+		t.Fatalf("got %d stats, want 1: %+v", len(stats), stats)
+	}
+	if stats[0].Total != 2 {
+		t.Errorf("counted %d crossings, want the car and the truck", stats[0].Total)
+	}
+	// with y pointing down, below the line is the right side of A -> B
+	if stats[0].Direction != DirectionForward {
+		t.Errorf("direction = %s, want %s", stats[0].Direction, DirectionForward)
+	}
+}
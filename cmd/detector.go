@@ -14,63 +14,28 @@ import (
 )
 
 type Detection struct {
-	ClassID    int
-	ClassName  string
-	Confidence float32
-	BBox       BoundingBox
+	Model      string      `json:"model"` // name of the model that produced the detection
+	ClassID    int         `json:"classId"`
+	ClassName  string      `json:"className"`
+	Confidence float32     `json:"confidence"`
+	BBox       BoundingBox `json:"bbox"`
+	Zones      []string    `json:"zones,omitempty"` // names of the zones the box bottom center is in
+	Lane       string      `json:"lane,omitempty"`
 }
 
 type BoundingBox struct {
-	XMin float32
-	YMin float32
-	XMax float32
-	YMax float32
+	XMin float32 `json:"xMin"`
+	YMin float32 `json:"yMin"`
+	XMax float32 `json:"xMax"`
+	YMax float32 `json:"yMax"`
 }
 
 const (
 	ModelPath = "./models/yolo11n_mAP50-0697.onnx"
+	// reference size of the detection space, boxes of every model are scaled to it
+	InputSize = 640
 )
 
-func LoadDetectionModel() (*onnxruntime_go.Session[float32], []*onnxruntime_go.Tensor[float32], []*onnxruntime_go.Tensor[float32]) {
-
-	inputs, outputs, err := onnxruntime_go.GetInputOutputInfo(ModelPath)
-	if err != nil {
-		fmt.Printf("Error getting input/output info: %v", err)
-	}
-	inputTensors, errs := createTensors[float32](inputs)
-	if errs != nil {
-		for i := range errs {
-			fmt.Printf("Errror creating inputTensor: %v", errs[i])
-		}
-	}
-	outputTensors, errs := createTensors[float32](outputs)
-	if errs != nil {
-		for i := range errs {
-			fmt.Printf("Error crateing outputTensor: %v", errs[i])
-		}
-	}
-	var inputNames []string
-	for i := range inputs {
-		fmt.Printf("\nmodel inputname: %v", inputs[i])
-		inputNames = append(inputNames, inputs[i].Name)
-	}
-	var outputNames []string
-	for i := range outputs {
-		fmt.Printf("\nmodel outputname: %v", outputs[i])
-		outputNames = append(outputNames, outputs[i].Name)
-	}
-
-	session, err := onnxruntime_go.NewSession(ModelPath, inputNames, outputNames, inputTensors, outputTensors)
-	if err != nil {
-		fmt.Printf("Error creating ONNX session: %v\n", err)
-		return nil, nil, nil
-	}
-
-	fmt.Printf("\nonnxruntime version: %v", onnxruntime_go.GetVersion())
-	return session, inputTensors, outputTensors
-
-}
-
 func createTensors[T onnxruntime_go.TensorData](infos []onnxruntime_go.InputOutputInfo) ([]*onnxruntime_go.Tensor[T], []error) {
 	var tensors []*onnxruntime_go.Tensor[T]
 	var errs []error
@@ -92,84 +57,175 @@ func createTensors[T onnxruntime_go.TensorData](infos []onnxruntime_go.InputOutp
 	return tensors, errs
 }
 
+/**
+ * Detection loop picking up new frames from all streams. Models with a
+ * dynamic batch dimension get the frames of every camera in one run,
+ * otherwise the cameras are processed round-robin one frame at a time.
+ * Runs until app.Done is closed, started with app.Workers.Add(1).
+ * @param *app
+ */
+func runDetectionLoop(app *App) {
+	defer app.Workers.Done()
+	lastID := -1
+	for {
+		select {
+		case <-app.Done:
+			return
+		default:
+		}
+
+		var ready []*CameraStream
+		var frames []image.Image
+		for _, stream := range app.activeStreams() {
+			if img, ok := stream.nextFrame(); ok {
+				ready = append(ready, stream)
+				frames = append(frames, img)
+			}
+		}
+		if len(ready) == 0 {
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+
+		app.ModelsMu.RLock()
+		if !allBatched(app.Models) {
+			// next camera after the one processed last, the other frames are skipped
+			pick := 0
+			for i, stream := range ready {
+				if stream.Device.ID > lastID {
+					pick = i
+					break
+				}
+			}
+			ready = ready[pick : pick+1]
+			frames = frames[pick : pick+1]
+			lastID = ready[0].Device.ID
+		}
+
+		app.processVideoFeed(ready, frames)
+		app.ModelsMu.RUnlock()
+	}
+}
+
+func allBatched(models []*Model) bool {
+	for _, model := range models {
+		if !model.Batched() {
+			return false
+		}
+	}
+	return true
+}
+
 /**
  * Set of functions that take frames as parameter and
  * modify the input tensors data accordingly, then parses
  * the output tensors and lastly update UI (video feed and text section)
  * with results.
- * @param image.Image, *App
- * @return image.Image
+ * @param *app, streams []*CameraStream, frames []image.Image one per stream
  */
-func (app *App) processVideoFeed(img image.Image) image.Image {
-	if app.Detector == nil || len(app.InputTensors) == 0 || len(app.OutputTensors) == 0 {
-		app.DataLabel.SetText("Model not properly loaded")
-		return img
+func (app *App) processVideoFeed(streams []*CameraStream, frames []image.Image) {
+	if len(app.Models) == 0 {
+		app.UI.SetData("Model not properly loaded")
+		return
 	}
 
 	startTime := time.Now()
 
-	err := updateInputTensorWithImage(app.InputTensors[0], img)
-	if err != nil {
-		app.DataLabel.SetText(fmt.Sprintf("Error updating tensor: %v", err))
-		return img
+	inputs := make([]FrameInput, len(streams))
+	for i, stream := range streams {
+		zones := app.cameraZones(stream.Device)
+		inputs[i] = FrameInput{
+			Image:   frames[i],
+			Regions: app.cameraTiling(stream.Device).Regions(frames[i].Bounds(), zones),
+		}
 	}
 
-	err = app.Detector.Run()
+	results, err := runModels(app.Models, inputs)
 	if err != nil {
-		app.DataLabel.SetText(fmt.Sprintf("Error running model: %v", err))
-		return img
+		app.UI.SetData(err.Error())
+		return
 	}
 
-	results := app.parseOutputTensor(app.OutputTensors[0])
-
-	annotatedImg := drawDetectionResults(img, results)
+	for i, stream := range streams {
+		previous := stream.SignState
+		postprocessStart := time.Now()
+		annotatedImg, err := stream.processDetections(frames[i], results[i], startTime)
+		metricPostprocessSeconds.WithLabelValues(cameraLabel(stream.Device)).Observe(time.Since(postprocessStart).Seconds())
+		for _, det := range stream.Detections {
+			metricDetections.WithLabelValues(cameraLabel(stream.Device), det.ClassName).Inc()
+		}
+		inferenceTime := time.Since(startTime)
+		stream.mu.Lock()
+		stream.InferenceTime = inferenceTime
+		stream.mu.Unlock()
+		if err != nil {
+			fmt.Printf("Dropping frame of %s: %v\n", stream.Device.Name, err)
+		} else {
+			stream.setImage(annotatedImg)
+		}
+		app.WSHub.PublishDetections(stream)
 
-	updateClassificationUI(app, results)
+		if stream.SignState != previous && app.Config.Snapshots.OnStateChange {
+			if _, err := stream.takeSnapshot(fmt.Sprintf("%s -> %s", previous, stream.SignState)); err != nil {
+				fmt.Printf("Error taking snapshot: %v\n", err)
+			}
+		}
 
-	elapsedMs := time.Since(startTime).Milliseconds()
-	app.DataLabel.SetText(fmt.Sprintf("Inference time: %dms", elapsedMs))
+		if stream.Device.ID == app.activeCamera() {
+			app.UI.ShowDetections(stream)
+			elapsedMs := inferenceTime.Milliseconds()
+			app.UI.SetData(fmt.Sprintf("Inference time: %dms (%d cameras)", elapsedMs, len(streams)))
+		}
+	}
 
-	return annotatedImg
+	updateSignState(app)
 }
 
-func updateInputTensorWithImage(tensor *onnxruntime_go.Tensor[float32], img image.Image) error {
-	// size should match models training image size
-	size := 640
-
+/**
+ * Resize the frame to the model input size and convert it to
+ * normalized NCHW float data.
+ * @param image.Image, width, height int
+ * @return []float32, error
+ */
+func preprocessImage(img image.Image, width, height int) ([]float32, error) {
 	mat, err := gocv.ImageToMatRGBA(img)
 	if err != nil {
-		return fmt.Errorf("failed to convert image to Mat: %v", err)
+		return nil, fmt.Errorf("failed to convert image to Mat: %v", err)
 	}
 	defer mat.Close()
 
 	resized := gocv.NewMat()
 	defer resized.Close()
-	gocv.Resize(mat, &resized, image.Point{X: size, Y: size}, 0, 0, gocv.InterpolationLinear)
+	gocv.Resize(mat, &resized, image.Point{X: width, Y: height}, 0, 0, gocv.InterpolationLinear)
 
 	rgbMat := gocv.NewMat()
 	defer rgbMat.Close()
 	gocv.CvtColor(resized, &rgbMat, gocv.ColorBGRToRGB)
 
-	tensorData := tensor.GetData()
+	tensorData := make([]float32, 3*width*height)
 
 	for c := 0; c < 3; c++ {
-		for y := 0; y < size; y++ {
-			for x := 0; x < size; x++ {
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
 				pixel := rgbMat.GetVecbAt(y, x)
-				tensorData[c*size*size+y*size+x] = float32(pixel[c]) / 255.0
+				tensorData[c*width*height+y*width+x] = float32(pixel[c]) / 255.0
 			}
 		}
 	}
 
-	return nil
+	return tensorData, nil
 }
-func (app *App) parseOutputTensor(tensor *onnxruntime_go.Tensor[float32]) []Detection {
-	outputData := tensor.GetData()
-	shape := tensor.GetShape()
 
-	// adjustable thresholds for filtering detections
-	const iouThreshold = 0.45
-	var confThreshold float32
+/**
+ * Parse YOLO output of a single image. Boxes are scaled from the
+ * model input size into the InputSize detection space.
+ * @param outputData []float32, shape onnxruntime_go.Shape [1, 4+classes, boxes]
+ * @return []Detection
+ */
+func (m *Model) parseOutput(outputData []float32, shape onnxruntime_go.Shape) []Detection {
+	confThreshold := m.Config.ConfThreshold
+	scaleX := float32(InputSize) / float32(m.Width)
+	scaleY := float32(InputSize) / float32(m.Height)
 
 	numDetections := int(shape[2])
 	numValues := int(shape[1])
@@ -190,14 +246,9 @@ func (app *App) parseOutputTensor(tensor *onnxruntime_go.Tensor[float32]) []Dete
 		classID := 0
 		maxProb := classProbabilities[0]
 		for c := 1; c < len(classProbabilities); c++ {
-			// set thershold high for default case (accident)
-			confThreshold = 0.25
-
 			if classProbabilities[c] > maxProb {
 				maxProb = classProbabilities[c]
 				classID = c
-				// lower if class is vehicle
-				confThreshold = 0.25
 			}
 		}
 
@@ -205,14 +256,15 @@ func (app *App) parseOutputTensor(tensor *onnxruntime_go.Tensor[float32]) []Dete
 			continue
 		}
 
-		xMin := x - w/2
-		yMin := y - h/2
-		xMax := x + w/2
-		yMax := y + h/2
+		xMin := (x - w/2) * scaleX
+		yMin := (y - h/2) * scaleY
+		xMax := (x + w/2) * scaleX
+		yMax := (y + h/2) * scaleY
 
 		results = append(results, Detection{
+			Model:      m.Config.Name,
 			ClassID:    classID,
-			ClassName:  getClassName(classID),
+			ClassName:  m.className(classID),
 			Confidence: maxProb,
 			BBox: BoundingBox{
 				XMin: xMin,
@@ -223,12 +275,12 @@ func (app *App) parseOutputTensor(tensor *onnxruntime_go.Tensor[float32]) []Dete
 		})
 	}
 
-	results = app.applyNMS(results, iouThreshold)
+	results = applyNMS(results, m.Config.IoUThreshold)
 
 	return results
 }
 
-func (app *App) calculateIoU(box1, box2 BoundingBox) float32 {
+func calculateIoU(box1, box2 BoundingBox) float32 {
 	xMin := max(box1.XMin, box2.XMin)
 	yMin := max(box1.YMin, box2.YMin)
 	xMax := min(box1.XMax, box2.XMax)
@@ -261,7 +313,7 @@ func max(a, b float32) float32 {
 	return b
 }
 
-func (app *App) applyNMS(detections []Detection, iouThreshold float32) []Detection {
+func applyNMS(detections []Detection, iouThreshold float32) []Detection {
 	if len(detections) == 0 {
 		return detections
 	}
@@ -279,7 +331,7 @@ func (app *App) applyNMS(detections []Detection, iouThreshold float32) []Detecti
 
 		for j := 0; j < len(result); j++ {
 			if detections[i].ClassID == result[j].ClassID {
-				iou := app.calculateIoU(detections[i].BBox, result[j].BBox)
+				iou := calculateIoU(detections[i].BBox, result[j].BBox)
 
 				if iou > iouThreshold {
 					keep = false
@@ -293,7 +345,6 @@ func (app *App) applyNMS(detections []Detection, iouThreshold float32) []Detecti
 		}
 	}
 
-	app.Detections = result
 	return result
 }
 func getClassName(classID int) string {
@@ -308,22 +359,33 @@ func getClassName(classID int) string {
 	return fmt.Sprintf("Class %d", classID)
 }
 
-func drawDetectionResults(img image.Image, results []Detection) image.Image {
+/**
+ * Camera specific geometry drawn below the detections.
+ */
+type Overlay struct {
+	Zones []Zone
+	Lines []CountingLine
+}
+
+func drawDetectionResults(img image.Image, results []Detection, tracks []*Track, overlay Overlay) image.Image {
 	mat, err := gocv.ImageToMatRGBA(img)
 	if err != nil {
 		return img
 	}
 	defer mat.Close()
 
-	colorMap := map[int]color.RGBA{
-		0: {220, 0, 0, 220}, // Red for accident
-		1: {0, 220, 0, 220}, // Green for vehicle
+	drawZones(&mat, overlay.Zones)
+	drawCountingLines(&mat, overlay.Lines)
+
+	colorMap := map[string]color.RGBA{
+		"accident": {220, 0, 0, 220}, // Red for accident
+		"vehicle":  {0, 220, 0, 220}, // Green for vehicle
 	}
 
 	imgWidth := mat.Cols()
 	imgHeight := mat.Rows()
-	scaleX := float32(imgWidth) / 640.0
-	scaleY := float32(imgHeight) / 640.0
+	scaleX := float32(imgWidth) / InputSize
+	scaleY := float32(imgHeight) / InputSize
 
 	for _, res := range results {
 		xMin := int(res.BBox.XMin * scaleX)
@@ -331,7 +393,7 @@ func drawDetectionResults(img image.Image, results []Detection) image.Image {
 		xMax := int(res.BBox.XMax * scaleX)
 		yMax := int(res.BBox.YMax * scaleY)
 
-		boxColor, ok := colorMap[res.ClassID]
+		boxColor, ok := colorMap[res.ClassName]
 		if !ok {
 			boxColor = color.RGBA{0, 0, 255, 255} // Default blue
 		}
@@ -350,6 +412,26 @@ func drawDetectionResults(img image.Image, results []Detection) image.Image {
 		gocv.PutText(&mat, text, textPoint, gocv.FontHersheySimplex, 0.5, color.RGBA{255, 255, 255, 255}, 1)
 	}
 
+	// track ids and trails of the box bottom centers
+	trackColor := color.RGBA{255, 200, 0, 255}
+	for _, track := range tracks {
+		for i := 1; i < len(track.History); i++ {
+			x0, y0 := track.History[i-1].BBox.Anchor()
+			x1, y1 := track.History[i].BBox.Anchor()
+			gocv.Line(&mat,
+				image.Pt(int(x0*scaleX), int(y0*scaleY)),
+				image.Pt(int(x1*scaleX), int(y1*scaleY)),
+				trackColor, 2)
+		}
+
+		text := fmt.Sprintf("#%d", track.ID)
+		if track.Speed > 0 {
+			text = fmt.Sprintf("#%d %.0f km/h", track.ID, track.Speed)
+		}
+		textPoint := image.Point{X: int(track.BBox.XMin * scaleX), Y: int(track.BBox.YMax*scaleY) + 15}
+		gocv.PutText(&mat, text, textPoint, gocv.FontHersheySimplex, 0.5, trackColor, 2)
+	}
+
 	imgDet, err := mat.ToImage()
 	if err != nil {
 		return img
@@ -357,8 +439,9 @@ func drawDetectionResults(img image.Image, results []Detection) image.Image {
 	return imgDet
 }
 
-func updateClassificationUI(app *App, results []Detection) {
+func updateClassificationUI(app *App, stream *CameraStream) {
 	var body strings.Builder
+	results := stream.Detections
 
 	classCounts := make(map[string]int)
 	for _, res := range results {
@@ -380,27 +463,58 @@ func updateClassificationUI(app *App, results []Detection) {
 		if i >= maxResults {
 			break
 		}
-		body.WriteString(fmt.Sprintf("%d. %s: %.1f%% [%.0f,%.0f,%.0f,%.0f]\n",
-			i+1, res.ClassName, res.Confidence*100,
+		body.WriteString(fmt.Sprintf("%d. %s/%s: %.1f%% [%.0f,%.0f,%.0f,%.0f]\n",
+			i+1, res.Model, res.ClassName, res.Confidence*100,
 			res.BBox.XMin, res.BBox.YMin, res.BBox.XMax, res.BBox.YMax))
 
 	}
+	body.WriteString("\n")
 
-	if searchAccident(results) {
+	body.WriteString(fmt.Sprintf("Active Tracks: %d\n", len(stream.Tracks)))
+	body.WriteString("----------------\n")
+	for i, track := range stream.Tracks {
+		if i >= maxResults {
+			break
+		}
+		body.WriteString(fmt.Sprintf("#%d %s: age %d, seen %.1fs\n",
+			track.ID, track.ClassName, track.Age, track.LastSeen.Sub(track.FirstSeen).Seconds()))
+	}
 
-		speedSign.File = "./FyneTest/50Speed.png" // Switch to new image
-		warningSign.File = "./FyneTest/WarningAccident.png"
-		speedSign.Refresh() // Force UI refresh
-		warningSign.Refresh()
+	for _, track := range stream.StoppedTracks {
+		body.WriteString(fmt.Sprintf("#%d stopped\n", track.ID))
+	}
+	for _, track := range stream.WrongWayTracks {
+		body.WriteString(fmt.Sprintf("#%d WRONG WAY on %s\n", track.ID, track.Lane))
+	}
+	for _, track := range stream.HazardTracks {
+		body.WriteString(fmt.Sprintf("#%d %s on the road\n", track.ID, track.ClassName))
+	}
 
-	} else {
+	if stats := stream.Counter.Stats(time.Now()); len(stats) > 0 {
+		body.WriteString("\nTraffic Flow (1/5/15 min, veh/h):\n")
+		body.WriteString("----------------\n")
+		for _, stat := range stats {
+			body.WriteString(fmt.Sprintf("%s %s %s: %d/%d/%d, %.0f veh/h (total %d)\n",
+				stat.Line, stat.Lane, stat.Direction,
+				stat.Counts[0], stat.Counts[1], stat.Counts[2], stat.Rates[2], stat.Total))
+		}
+	}
 
-		speedSign.File = "./FyneTest/100Speed.png" // Switch back
-		warningSign.File = "./FyneTest/Blank.png"
-		speedSign.Refresh()
-		warningSign.Refresh()
+	averages := stream.SpeedEstimator.LaneAverages()
+	if len(averages) > 0 {
+		body.WriteString("\nAverage Speed (1 min):\n")
+		body.WriteString("----------------\n")
+		for _, lane := range sortedLanes(averages) {
+			name := lane
+			if name == "" {
+				name = "no lane"
+			}
+			body.WriteString(fmt.Sprintf("%s: %.0f km/h\n", name, averages[lane]))
+		}
 	}
 
+	body.WriteString(fmt.Sprintf("\nCamera state: %s, limit %d km/h\n", stream.SignState, stream.SpeedLimit))
+
 	app.DataBody.SetText(body.String())
 	app.DataBody.Refresh()
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventSignState      EventType = "sign_state"
+	EventSpeedLimit     EventType = "speed_limit"
+	EventStoppedVehicle EventType = "stopped_vehicle"
+	EventWrongWay       EventType = "wrong_way"
+	EventHazard         EventType = "hazard"
+	EventCameraFault    EventType = "camera_fault"
+	EventModelReload    EventType = "model_reload"
+	EventOverride       EventType = "override"
+)
+
+type Event struct {
+	ID       int64     `json:"id"` // set once stored in the EventStore
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"type"`
+	CameraID int       `json:"cameraId"` // -1 for events of the whole sign, e.g. overrides and model reloads
+	TrackID  int       `json:"trackId"`
+	Message  string    `json:"message"`
+}
+
+/**
+ * Record an event raised by the detection or sign logic,
+ * persisting it when the event store is open.
+ * @param *app, Event
+ */
+func (app *App) emitEvent(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	fmt.Printf("[%s] %s camera %d: %s\n", event.Time.Format(time.TimeOnly), event.Type, event.CameraID, event.Message)
+
+	if app.EventStore != nil {
+		id, err := app.EventStore.Insert(event)
+		if err != nil {
+			fmt.Printf("Error storing event: %v\n", err)
+		}
+		event.ID = id
+	}
+	if app.MQTT != nil {
+		app.MQTT.PublishEvent(event)
+	}
+	if app.WSHub != nil {
+		app.WSHub.Broadcast(WSMessage{Type: WSMessageEvent, Time: event.Time, CameraID: event.CameraID, Data: event}, true)
+	}
+}
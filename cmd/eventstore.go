@@ -0,0 +1,192 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const eventSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	time      INTEGER NOT NULL, -- unix milliseconds
+	type      TEXT    NOT NULL,
+	camera_id INTEGER NOT NULL,
+	track_id  INTEGER NOT NULL,
+	message   TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_time ON events (time);
+CREATE INDEX IF NOT EXISTS events_type ON events (type, time);
+`
+
+/**
+ * Persistent event log in a SQLite database.
+ */
+type EventStore struct {
+	db *sql.DB
+}
+
+/**
+ * Open or create the event database.
+ * @param path string
+ * @return *EventStore, error
+ */
+func OpenEventStore(path string) (*EventStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event store %s: %v", path, err)
+	}
+	// sqlite allows a single writer, serialize access instead of retrying on busy
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(eventSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create event schema: %v", err)
+	}
+	return &EventStore{db: db}, nil
+}
+
+func (s *EventStore) Close() error {
+	return s.db.Close()
+}
+
+/**
+ * Store an event.
+ * @param Event
+ * @return id int64, error
+ */
+func (s *EventStore) Insert(event Event) (int64, error) {
+	result, err := s.db.Exec(
+		"INSERT INTO events (time, type, camera_id, track_id, message) VALUES (?, ?, ?, ?, ?)",
+		event.Time.UnixMilli(), string(event.Type), event.CameraID, event.TrackID, event.Message,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store event: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+/**
+ * Filter for event queries, zero values match everything.
+ */
+type EventQuery struct {
+	From     time.Time
+	To       time.Time
+	Types    []EventType
+	CameraID *int
+	Limit    int
+}
+
+func (q EventQuery) where() (string, []any) {
+	var clauses []string
+	var args []any
+
+	if !q.From.IsZero() {
+		clauses = append(clauses, "time >= ?")
+		args = append(args, q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		clauses = append(clauses, "time < ?")
+		args = append(args, q.To.UnixMilli())
+	}
+	if len(q.Types) > 0 {
+		placeholders := make([]string, len(q.Types))
+		for i, t := range q.Types {
+			placeholders[i] = "?"
+			args = append(args, string(t))
+		}
+		clauses = append(clauses, "type IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if q.CameraID != nil {
+		clauses = append(clauses, "camera_id = ?")
+		args = append(args, *q.CameraID)
+	}
+
+	if len(clauses) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+/**
+ * Events matching the query, oldest first.
+ * @param EventQuery
+ * @return []Event, error
+ */
+func (s *EventStore) Query(q EventQuery) ([]Event, error) {
+	where, args := q.where()
+	query := "SELECT id, time, type, camera_id, track_id, message FROM events" + where + " ORDER BY time, id"
+	if q.Limit > 0 {
+		// newest events when limited, still returned oldest first
+		query = "SELECT * FROM (SELECT id, time, type, camera_id, track_id, message FROM events" + where +
+			" ORDER BY time DESC, id DESC LIMIT ?) ORDER BY time, id"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var event Event
+		var millis int64
+		var eventType string
+		if err := rows.Scan(&event.ID, &millis, &eventType, &event.CameraID, &event.TrackID, &event.Message); err != nil {
+			return nil, fmt.Errorf("failed to read event: %v", err)
+		}
+		event.Time = time.UnixMilli(millis)
+		event.Type = EventType(eventType)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+/**
+ * Write the events matching the query into a csv file under ExportDir.
+ * @param EventQuery
+ * @return path string, error
+ */
+func (s *EventStore) ExportCSV(q EventQuery) (string, error) {
+	events, err := s.Query(q)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(ExportDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export dir: %v", err)
+	}
+
+	path := filepath.Join(ExportDir, fmt.Sprintf("events_%s.csv", time.Now().Format("20060102_150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"id", "time", "type", "camera", "track", "message"})
+	for _, event := range events {
+		w.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.Time.Format(time.RFC3339Nano),
+			string(event.Type),
+			strconv.Itoa(event.CameraID),
+			strconv.Itoa(event.TrackID),
+			event.Message,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventQueryWhere(t *testing.T) {
+	from := time.UnixMilli(1700000000000)
+	to := from.Add(time.Hour)
+	camera := 2
+
+	tests := []struct {
+		name      string
+		query     EventQuery
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "everything",
+			query:     EventQuery{},
+			wantWhere: "",
+		},
+		{
+			name:      "time range",
+			query:     EventQuery{From: from, To: to},
+			wantWhere: " WHERE time >= ? AND time < ?",
+			wantArgs:  []any{from.UnixMilli(), to.UnixMilli()},
+		},
+		{
+			name:      "types",
+			query:     EventQuery{Types: []EventType{EventWrongWay, EventHazard}},
+			wantWhere: " WHERE type IN (?, ?)",
+			wantArgs:  []any{"wrong_way", "hazard"},
+		},
+		{
+			name:      "camera zero is a filter",
+			query:     EventQuery{CameraID: new(int)},
+			wantWhere: " WHERE camera_id = ?",
+			wantArgs:  []any{0},
+		},
+		{
+			name:      "all filters",
+			query:     EventQuery{From: from, Types: []EventType{EventSignState}, CameraID: &camera, Limit: 5},
+			wantWhere: " WHERE time >= ? AND type IN (?) AND camera_id = ?",
+			wantArgs:  []any{from.UnixMilli(), "sign_state", 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			where, args := test.query.where()
+			if where != test.wantWhere {
+				t.Errorf("where = %q, want %q", where, test.wantWhere)
+			}
+			if len(args) != 0 || len(test.wantArgs) != 0 {
+				if !reflect.DeepEqual(args, test.wantArgs) {
+					t.Errorf("args = %v, want %v", args, test.wantArgs)
+				}
+			}
+		})
+	}
+}
+
+func TestEventStoreQuery(t *testing.T) {
+	store, err := OpenEventStore(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	start := time.UnixMilli(1700000000000)
+	events := []Event{
+		{Time: start, Type: EventSignState, CameraID: 0, Message: "normal -> accident"},
+		{Time: start.Add(1 * time.Minute), Type: EventWrongWay, CameraID: 1, TrackID: 7, Message: "track 7"},
+		{Time: start.Add(2 * time.Minute), Type: EventHazard, CameraID: 1, TrackID: 9, Message: "person"},
+		{Time: start.Add(3 * time.Minute), Type: EventOverride, CameraID: -1, Message: "set by operator"},
+	}
+	for _, event := range events {
+		if _, err := store.Insert(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	camera := 1
+	tests := []struct {
+		name  string
+		query EventQuery
+		want  []string
+	}{
+		{"all, oldest first", EventQuery{}, []string{"normal -> accident", "track 7", "person", "set by operator"}},
+		{"from is inclusive, to exclusive", EventQuery{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)}, []string{"track 7", "person"}},
+		{"type", EventQuery{Types: []EventType{EventOverride}}, []string{"set by operator"}},
+		{"camera", EventQuery{CameraID: &camera}, []string{"track 7", "person"}},
+		{"limit keeps the newest", EventQuery{Limit: 2}, []string{"person", "set by operator"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := store.Query(test.query)
+			if err != nil {
+				t.Fatalf("Query() error: %v", err)
+			}
+			var messages []string
+			for _, event := range got {
+				messages = append(messages, event.Message)
+			}
+			if !reflect.DeepEqual(messages, test.want) {
+				t.Errorf("Query() = %v, want %v", messages, test.want)
+			}
+		})
+	}
+}
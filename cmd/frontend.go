@@ -0,0 +1,30 @@
+package main
+
+/**
+ * Everything the capture, detection and sign logic shows to an operator.
+ * The desktop window implements it with fyne widgets, in headless mode
+ * the status goes to the log and the network APIs only.
+ * The methods are called from the capture and detection goroutines.
+ */
+type UI interface {
+	// short status message, e.g. "Found 2 cameras"
+	SetStatus(text string)
+	// detection loop info, e.g. the inference time
+	SetData(text string)
+	// latest detections and tracks of the focused camera
+	ShowDetections(stream *CameraStream)
+	// new annotated frame of a stream, or a new focused camera
+	ShowFrame(stream *CameraStream)
+	// sign composition after a state or limit change
+	ShowSigns(state SignState, speedLimit int)
+	// active manual override, nil in automatic mode
+	ShowOverride(override *Override)
+	// camera list after detection
+	CamerasChanged()
+	// streams started or stopped
+	StreamsChanged()
+	// snapshot taken
+	SnapshotsChanged()
+	// block until the app is closed
+	Run()
+}
@@ -0,0 +1,35 @@
+package main
+
+/**
+ * Point in normalized frame coordinates, (0,0) is the top left
+ * and (1,1) the bottom right corner of the frame.
+ */
+type Point struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+/**
+ * Convert a point from detection space (InputSize x InputSize)
+ * into normalized frame coordinates.
+ */
+func normalizePoint(x, y float32) Point {
+	return Point{X: x / InputSize, Y: y / InputSize}
+}
+
+/**
+ * Ray casting point in polygon test.
+ * @param p Point, polygon []Point
+ * @return bool
+ */
+func pointInPolygon(p Point, polygon []Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
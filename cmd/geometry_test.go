@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestPointInPolygon(t *testing.T) {
+	square := []Point{{0.2, 0.2}, {0.8, 0.2}, {0.8, 0.8}, {0.2, 0.8}}
+	// U shape open to the top, the notch is outside
+	notched := []Point{{0.1, 0.1}, {0.3, 0.1}, {0.3, 0.6}, {0.7, 0.6}, {0.7, 0.1}, {0.9, 0.1}, {0.9, 0.9}, {0.1, 0.9}}
+	triangle := []Point{{0.5, 0.1}, {0.9, 0.9}, {0.1, 0.9}}
+
+	tests := []struct {
+		name    string
+		p       Point
+		polygon []Point
+		want    bool
+	}{
+		{"inside square", Point{0.5, 0.5}, square, true},
+		{"left of square", Point{0.1, 0.5}, square, false},
+		{"below square", Point{0.5, 0.9}, square, false},
+		{"inside notched base", Point{0.5, 0.8}, notched, true},
+		{"inside notched arm", Point{0.2, 0.3}, notched, true},
+		{"in the notch", Point{0.5, 0.3}, notched, false},
+		{"inside triangle", Point{0.5, 0.6}, triangle, true},
+		{"beside triangle tip", Point{0.2, 0.3}, triangle, false},
+		{"no polygon", Point{0.5, 0.5}, nil, false},
+		{"degenerate line", Point{0.5, 0.5}, []Point{{0, 0}, {1, 1}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pointInPolygon(test.p, test.polygon); got != test.want {
+				t.Errorf("pointInPolygon(%v) = %v, want %v", test.p, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// badge color per sign state on the grid tiles
+var stateColors = map[SignState]color.RGBA{
+	SignStateNormal:         {0, 200, 0, 255},
+	SignStateAnimal:         {255, 200, 0, 255},
+	SignStatePedestrian:     {255, 200, 0, 255},
+	SignStateStoppedVehicle: {255, 128, 0, 255},
+	SignStateAccident:       {255, 0, 0, 255},
+	SignStateWrongWay:       {255, 0, 0, 255},
+}
+
+/**
+ * Tile of the camera grid in the Debug tab showing the annotated
+ * feed of one stream with its fps and state badge.
+ * Tapping the tile focuses the camera in the full-size view.
+ */
+type CameraTile struct {
+	widget.BaseWidget
+
+	app    *App
+	stream *CameraStream
+	video  *canvas.Raster
+	title  *canvas.Text
+	badge  *canvas.Rectangle
+	state  *canvas.Text
+}
+
+func NewCameraTile(app *App, stream *CameraStream) *CameraTile {
+	tile := &CameraTile{app: app, stream: stream}
+	tile.video = canvas.NewRaster(func(w, h int) image.Image {
+		if img := stream.CurrentImage.Load(); img != nil {
+			return img.(image.Image)
+		}
+		return image.NewRGBA(image.Rect(0, 0, w, h))
+	})
+	tile.video.SetMinSize(fyne.NewSize(320, 180))
+
+	tile.title = canvas.NewText(stream.Device.Name, color.White)
+	tile.title.TextStyle = fyne.TextStyle{Bold: true}
+	tile.badge = canvas.NewRectangle(stateColors[SignStateNormal])
+	tile.badge.CornerRadius = 4
+	tile.state = canvas.NewText("", color.Black)
+
+	tile.ExtendBaseWidget(tile)
+	return tile
+}
+
+func (t *CameraTile) CreateRenderer() fyne.WidgetRenderer {
+	header := container.NewHBox(
+		t.title,
+		container.NewStack(t.badge, container.NewPadded(t.state)),
+	)
+	return widget.NewSimpleRenderer(container.NewBorder(header, nil, nil, nil, t.video))
+}
+
+func (t *CameraTile) Tapped(*fyne.PointEvent) {
+	focusCamera(t.app, t.stream.Device.ID)
+	t.app.GridCheck.SetChecked(false)
+}
+
+/**
+ * Update the fps, state badge and video of the tile.
+ */
+func (t *CameraTile) Refresh() {
+	// called from the capture goroutine, the detection loop writes the state
+	status := t.stream.Status()
+	t.title.Text = fmt.Sprintf("%s  %.1f fps", status.Camera.Name, status.FPS)
+	t.state.Text = status.SignState
+	t.badge.FillColor = stateColors[status.state]
+	t.BaseWidget.Refresh()
+}
+
+/**
+ * Rebuild the camera grid after streams were started or stopped.
+ * @param *app
+ */
+func updateCameraGrid(app *App) {
+	if app.CameraGrid == nil {
+		return
+	}
+
+	streams := app.activeStreams()
+	tiles := make([]fyne.CanvasObject, len(streams))
+	for i, stream := range streams {
+		tile := NewCameraTile(app, stream)
+		stream.setTile(tile)
+		tiles[i] = tile
+	}
+
+	columns := int(math.Ceil(math.Sqrt(float64(len(streams)))))
+	if columns == 0 {
+		columns = 1
+	}
+	app.CameraGrid.Objects = []fyne.CanvasObject{container.NewGridWithColumns(columns, tiles...)}
+	app.CameraGrid.Refresh()
+}
+
+/**
+ * Switch the Debug tab between the camera grid and the focused camera.
+ * @param *app, grid bool
+ */
+func showCameraGrid(app *App, grid bool) {
+	if grid {
+		app.VideoView.Hide()
+		app.CameraGrid.Show()
+	} else {
+		app.CameraGrid.Hide()
+		app.VideoView.Show()
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type HazardKind string
+
+const (
+	HazardPedestrian HazardKind = "pedestrian"
+	HazardAnimal     HazardKind = "animal"
+)
+
+/**
+ * Classes treated as hazards when they are on the road. The names
+ * are model labels, so any model with matching labels (e.g. COCO)
+ * drives the hazard warnings.
+ */
+type HazardConfig struct {
+	PedestrianClasses []string `json:"pedestrianClasses"`
+	AnimalClasses     []string `json:"animalClasses"`
+	// seconds an object has to be on the road before it is a hazard
+	MinDuration float64 `json:"minDurationSeconds"`
+	// seconds the warning is kept after the object left
+	HoldSeconds float64 `json:"holdSeconds"`
+}
+
+/**
+ * Flags persons and animals inside the camera's include or lane
+ * zones. Without road zones the detector does nothing, a person
+ * on the sidewalk must not drive the pedestrian warning.
+ */
+type HazardDetector struct {
+	Config HazardConfig
+
+	classes  map[string]HazardKind
+	lastSeen map[HazardKind]time.Time
+	reported map[int]bool
+}
+
+func NewHazardDetector(config HazardConfig) *HazardDetector {
+	h := &HazardDetector{
+		Config:   config,
+		classes:  make(map[string]HazardKind),
+		lastSeen: make(map[HazardKind]time.Time),
+		reported: make(map[int]bool),
+	}
+	for _, class := range config.PedestrianClasses {
+		h.classes[class] = HazardPedestrian
+	}
+	for _, class := range config.AnimalClasses {
+		h.classes[class] = HazardAnimal
+	}
+	return h
+}
+
+/**
+ * Check tracks for hazard classes that have been on the road long
+ * enough. Every new hazard track raises an EventHazard.
+ * @param *stream, tracks []*Track, zones []Zone of the camera, now time.Time
+ * @return []*Track hazards on the road
+ */
+func (h *HazardDetector) Update(stream *CameraStream, tracks []*Track, zones []Zone, now time.Time) []*Track {
+	var hazards []*Track
+	seen := make(map[int]bool)
+	if !hasRoadZone(zones) {
+		tracks = nil
+	}
+
+	for _, track := range tracks {
+		kind, ok := h.classes[track.ClassName]
+		if !ok || !onRoad(track, zones) {
+			continue
+		}
+		seen[track.ID] = true
+
+		if now.Sub(track.FirstSeen).Seconds() < h.Config.MinDuration {
+			continue
+		}
+		hazards = append(hazards, track)
+		h.lastSeen[kind] = now
+
+		if !h.reported[track.ID] {
+			h.reported[track.ID] = true
+			stream.queueEvent(Event{
+				Time:    now,
+				Type:    EventHazard,
+				TrackID: track.ID,
+				Message: fmt.Sprintf("%s on the road (%s #%d)", kind, track.ClassName, track.ID),
+			})
+		}
+	}
+
+	for id := range h.reported {
+		if !seen[id] {
+			delete(h.reported, id)
+		}
+	}
+
+	return hazards
+}
+
+/**
+ * Whether a hazard of the kind was seen within the hold time.
+ * @param kind HazardKind, now time.Time
+ * @return bool
+ */
+func (h *HazardDetector) Active(kind HazardKind, now time.Time) bool {
+	last, ok := h.lastSeen[kind]
+	return ok && now.Sub(last).Seconds() < h.Config.HoldSeconds
+}
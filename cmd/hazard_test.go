@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHazardDetectorOnlyOnRoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   string
+		zones   []string
+		cameras []Zone
+		want    bool
+	}{
+		{"person on the road", "person", []string{"road"}, testRoadZones, true},
+		{"dog on the road", "dog", []string{"road"}, testRoadZones, true},
+		{"car on the road", "car", []string{"road"}, testRoadZones, false},
+		{"person on the sidewalk", "person", nil, testRoadZones, false},
+		{"no road zone configured", "person", nil, nil, false},
+	}
+
+	config := HazardConfig{PedestrianClasses: []string{"person"}, AnimalClasses: []string{"dog"}, MinDuration: 1, HoldSeconds: 10}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			detector := NewHazardDetector(config)
+			stream := &CameraStream{}
+			now := time.Now()
+			track := &Track{ID: 1, ClassName: test.class, Zones: test.zones, FirstSeen: now.Add(-2 * time.Second)}
+
+			hazards := detector.Update(stream, []*Track{track}, test.cameras, now)
+			if got := len(hazards) == 1; got != test.want {
+				t.Errorf("hazard = %v, want %v", got, test.want)
+			}
+			active := detector.Active(HazardPedestrian, now) || detector.Active(HazardAnimal, now)
+			if active != test.want {
+				t.Errorf("warning active = %v, want %v", active, test.want)
+			}
+		})
+	}
+}
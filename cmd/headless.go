@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// how often the headless mode logs the stream status
+const headlessStatusInterval = time.Minute
+
+/**
+ * UI for the roadside unit without a desktop session. Status and sign
+ * changes are logged, everything else is served by the HTTP API,
+ * WebSocket and MQTT.
+ */
+type headlessUI struct {
+	app *App
+
+	mu         sync.Mutex
+	lastSign   string
+	lastStatus string
+}
+
+func NewHeadlessUI(app *App) UI {
+	return &headlessUI{app: app}
+}
+
+func (h *headlessUI) SetStatus(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if text == h.lastStatus {
+		return
+	}
+	h.lastStatus = text
+	fmt.Printf("[%s] %s\n", time.Now().Format(time.TimeOnly), text)
+}
+
+// per frame info, served by /api/status instead
+func (h *headlessUI) SetData(string)               {}
+func (h *headlessUI) ShowDetections(*CameraStream) {}
+func (h *headlessUI) ShowFrame(*CameraStream)      {}
+func (h *headlessUI) SnapshotsChanged()            {}
+func (h *headlessUI) ShowOverride(*Override)       {}
+
+func (h *headlessUI) ShowSigns(state SignState, speedLimit int) {
+	sign := fmt.Sprintf("%s, %d km/h", state, speedLimit)
+	h.mu.Lock()
+	changed := sign != h.lastSign
+	h.lastSign = sign
+	h.mu.Unlock()
+	if changed {
+		fmt.Printf("[%s] sign shows %s\n", time.Now().Format(time.TimeOnly), sign)
+	}
+}
+
+func (h *headlessUI) CamerasChanged() {
+	for _, device := range h.app.CameraDevices {
+		fmt.Printf("Camera %d: %s (%s, %dx%d)\n", device.ID, device.Name, device.Path, device.Width, device.Height)
+	}
+}
+
+func (h *headlessUI) StreamsChanged() {
+	h.SetStatus(fmt.Sprintf("Streaming %d cameras", len(h.app.activeStreams())))
+}
+
+/**
+ * Log the stream status periodically until SIGINT or SIGTERM.
+ */
+func (h *headlessUI) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	h.run(ctx, headlessStatusInterval)
+}
+
+/**
+ * Log the stream status every interval until ctx is done.
+ * @param ctx context.Context, interval time.Duration
+ */
+func (h *headlessUI) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Shutting down")
+			return
+		case <-ticker.C:
+			h.logStatus()
+		}
+	}
+}
+
+func (h *headlessUI) logStatus() {
+	var cameras []string
+	for _, stream := range h.app.activeStreams() {
+		status := stream.Status()
+		cameras = append(cameras, fmt.Sprintf("%s %.1f fps %dms %d detections %s",
+			status.Camera.Name, status.FPS, status.InferenceMs, len(status.Detections), status.SignState))
+	}
+	if len(cameras) == 0 {
+		cameras = append(cameras, "no cameras streaming")
+	}
+	state, limit := h.app.currentSign()
+	fmt.Printf("[%s] sign %s, %d km/h; %s\n", time.Now().Format(time.TimeOnly),
+		state, limit, strings.Join(cameras, "; "))
+}
+
+/**
+ * Detect the cameras and stream all of them, there is nobody to pick one.
+ * @param *app
+ */
+func startAllCameras(app *App) {
+	DetectCameras(app)
+	for i := range app.CameraDevices {
+		startStream(app, i)
+	}
+}
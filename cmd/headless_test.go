@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+/**
+ * What f prints to stdout, the headless UI logs there.
+ */
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+func TestHeadlessUILogsChangesOnly(t *testing.T) {
+	ui := NewHeadlessUI(newTestApp(nil))
+
+	output := captureStdout(t, func() {
+		ui.SetStatus("Streaming 1 cameras")
+		ui.SetStatus("Streaming 1 cameras")
+		ui.SetStatus("Streaming 2 cameras")
+		ui.ShowSigns(SignStateNormal, 100)
+		ui.ShowSigns(SignStateNormal, 100)
+		ui.ShowSigns(SignStateAccident, 60)
+	})
+
+	for _, line := range []string{"Streaming 1 cameras", "Streaming 2 cameras", "sign shows normal, 100 km/h", "sign shows accident, 60 km/h"} {
+		if count := strings.Count(output, line); count != 1 {
+			t.Errorf("%q logged %d times, want once in:\n%s", line, count, output)
+		}
+	}
+}
+
+func TestHeadlessUIStreamsChanged(t *testing.T) {
+	app := newTestApp(nil)
+	ui := NewHeadlessUI(app)
+	app.Streams[0] = &CameraStream{Device: CameraDevice{ID: 0, Name: "front"}}
+
+	output := captureStdout(t, ui.StreamsChanged)
+	if !strings.Contains(output, "Streaming 1 cameras") {
+		t.Errorf("StreamsChanged() logged %q, want the number of streams", output)
+	}
+}
+
+func TestHeadlessUIRunLogsStatusUntilDone(t *testing.T) {
+	ui := NewHeadlessUI(newTestApp(nil)).(*headlessUI)
+
+	output := captureStdout(t, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		ui.run(ctx, 10*time.Millisecond)
+	})
+
+	if !strings.Contains(output, "sign normal, 100 km/h; no cameras streaming") {
+		t.Errorf("run() did not log the status:\n%s", output)
+	}
+	if !strings.HasSuffix(output, "Shutting down\n") {
+		t.Errorf("run() did not end with the shutdown message:\n%s", output)
+	}
+}
+
+func TestShutdownStopsDetectionLoop(t *testing.T) {
+	app := newTestApp(nil)
+	app.Workers.Add(1)
+	go runDetectionLoop(app)
+
+	stopped := make(chan bool)
+	go func() {
+		shutdown(app, nil)
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown() did not return, the detection loop is still running")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// UI that ignores everything, the tests run without a frontend
+type nopUI struct{}
+
+func (nopUI) SetStatus(string)             {}
+func (nopUI) SetData(string)               {}
+func (nopUI) ShowDetections(*CameraStream) {}
+func (nopUI) ShowFrame(*CameraStream)      {}
+func (nopUI) ShowSigns(SignState, int)     {}
+func (nopUI) ShowOverride(*Override)       {}
+func (nopUI) CamerasChanged()              {}
+func (nopUI) StreamsChanged()              {}
+func (nopUI) SnapshotsChanged()            {}
+func (nopUI) Run()                         {}
+
+/**
+ * App without cameras, models or frontend.
+ * @param config *Config, nil for DefaultConfig()
+ * @return *App
+ */
+func newTestApp(config *Config) *App {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return &App{
+		Config:     config,
+		Streams:    make(map[int]*CameraStream),
+		SpeedLimit: config.SpeedLimits.Default,
+		UI:         nopUI{},
+		Done:       make(chan bool),
+	}
+}
+
+func waitFor(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -11,11 +11,11 @@ import (
 )
 
 type CameraDevice struct {
-	ID     int
-	Name   string
-	Path   string
-	Width  int
-	Height int
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
 }
 
 /**
@@ -45,8 +45,7 @@ func FindVideoDevices() []string {
  * @param *app
  */
 func DetectCameras(app *App) {
-	app.StatusLabel.SetText("Scanning for cameras...")
-	app.StatusLabel.Refresh()
+	app.UI.SetStatus("Scanning for cameras...")
 
 	devices := FindVideoDevices()
 	var cameras []CameraDevice
@@ -109,68 +108,24 @@ func DetectCameras(app *App) {
 	}
 
 	app.CameraDevices = cameras
-	UpdateDeviceList(app)
-	app.StatusLabel.SetText(fmt.Sprintf("Camera detection completed"))
+	app.UI.CamerasChanged()
+	app.UI.SetStatus(fmt.Sprintf("Camera detection completed"))
 	time.Sleep(1000 * time.Millisecond)
-	app.StatusLabel.SetText(fmt.Sprintf("Found %d cameras", len(cameras)))
-	app.StatusLabel.Refresh()
+	app.UI.SetStatus(fmt.Sprintf("Found %d cameras", len(cameras)))
 }
 
 /**
- * Start streaming goroutine with selected device
- * @param *app, deviceID
+ * The device focused in the Debug tab.
+ * @return CameraDevice, bool false when no camera is focused
  */
-func startStream(app *App, deviceID int) {
-	if deviceID >= len(app.CameraDevices) {
-		return
-	}
-
-	if app.StopCurrent != nil {
-		close(app.StopCurrent)
-		app.StopCurrent = nil // reset the kill signal
-		time.Sleep(100 * time.Millisecond)
-	}
-	app.StopCurrent = make(chan bool)
-	stopChan := app.StopCurrent
-
-	// if problems with opening video, try different backend. V4L2 works for now.
-	cam, err := gocv.VideoCaptureFileWithAPI(app.CameraDevices[deviceID].Path, gocv.VideoCaptureV4L2)
-
-	if err != nil {
-		app.StatusLabel.SetText("Error opening device")
-		return
-	}
-
-	go func() {
-		defer cam.Close()
-		frame := gocv.NewMat()
-		defer frame.Close()
-
-		for {
-			select {
-			case <-stopChan:
-				app.StatusLabel.SetText("Stopping the video stream.")
-				return
-			default:
-				if ok := cam.Read(&frame); ok && !frame.Empty() {
-					app.StatusLabel.SetText("jamming")
-					img, _ := frame.ToImage()
-
-					if app.Detector != nil {
-						pImg := app.processVideoFeed(img)
-						app.DataLabel.SetText("jamming")
-						app.CurrentImage.Store(pImg)
-					} else {
-						app.DataLabel.SetText("No detection instance.")
-						app.CurrentImage.Store(img)
-					}
-
-					RefreshCanvas(app)
-				}
-				time.Sleep(33 * time.Millisecond) // ~30 FPS
-			}
+func (app *App) activeDevice() (CameraDevice, bool) {
+	active := app.activeCamera()
+	for _, device := range app.CameraDevices {
+		if device.ID == active {
+			return device, true
 		}
-	}()
+	}
+	return CameraDevice{}, false
 }
 
 /**
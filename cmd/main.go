@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"fyne.io/fyne/v2"
-	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/widget"
 	"github.com/yalue/onnxruntime_go"
@@ -18,60 +22,152 @@ import (
  * you should probably start from here.
  */
 type App struct {
-	// UI
+	// UI, the fyne widgets below are only set up by the desktop UI
+	UI            UI
 	Window        fyne.Window
 	MainContent   fyne.CanvasObject
 	ContentCanvas fyne.CanvasObject
 	ControlPanel  fyne.CanvasObject
 	VideoCanvas   *canvas.Raster
+	VideoView     fyne.CanvasObject
+	CameraGrid    *fyne.Container
 	StatusLabel   *widget.Label
 	DeviceSelect  *widget.Select
+	GridCheck     *widget.Check
 	DataLabel     *widget.Label
 	DataBody      *widget.TextGrid
+	ZoneEditor    *ZoneEditor
+	Gallery       *Gallery
+	Timeline      *Timeline
+	SignDisplay   *SignDisplay
 
 	// Video
 	CurrentImage  *atomic.Value
-	StopCurrent   chan bool
 	CameraDevices []CameraDevice
 	Video         *gocv.VideoCapture
+	Streams       map[int]*CameraStream
+	StreamsMu     sync.Mutex
 
 	// Detection
-	Detector      *onnxruntime_go.Session[float32]
-	InputTensors  []*onnxruntime_go.Tensor[float32]
-	OutputTensors []*onnxruntime_go.Tensor[float32]
-	Detections    []Detection
+	Models   []*Model
+	ModelsMu sync.RWMutex // held for writing while models are reloaded
+
+	// Sign logic
+	Config       *Config
+	ConfigMu     sync.RWMutex // guards Config.Cameras, edited from the UI
+	SpeedLimit   int
+	SignState    SignState
+	SignMu       sync.Mutex // guards SignState, SpeedLimit and SignDisplay
+	EventStore   *EventStore
+	WSHub        *WebSocketHub
+	MQTT         *MQTTPublisher
+	ActiveCamera int
+
+	// Manual override
+	Override      *Override
+	OverrideMu    sync.Mutex
+	OverrideLabel *widget.Label
+
+	// Shutdown, Done is closed once and no streams are started afterwards
+	Done    chan bool
+	Workers sync.WaitGroup // capture goroutines, recorder flushes and the detection loop
 }
 
 func main() {
+	headless := flag.Bool("headless", false, "run without a window, status is served by the network APIs only")
+	flag.Parse()
+
 	envErr := onnxruntime_go.InitializeEnvironment()
 	if envErr != nil {
 		fmt.Printf("Error initializing onnx environment: %v", envErr)
 	}
 	defer onnxruntime_go.DestroyEnvironment()
 
-	a := app.New()
-	w := a.NewWindow("SmartSign™")
-
-	accidentDetector, inputTensors, outputTensors := LoadDetectionModel()
-	defer accidentDetector.Destroy()
+	config, err := LoadConfig(ConfigPath)
+	if err != nil {
+		fmt.Printf("Error loading config, using defaults: %v\n", err)
+	}
 
 	app := &App{
-		Window:        w,
-		CurrentImage:  &atomic.Value{},
-		StopCurrent:   make(chan bool),
-		Detector:      accidentDetector,
-		InputTensors:  inputTensors,
-		OutputTensors: outputTensors,
+		CurrentImage: &atomic.Value{},
+		Streams:      make(map[int]*CameraStream),
+		ActiveCamera: -1,
+		Models:       LoadModels(config.Models),
+
+		Config:     config,
+		SpeedLimit: config.SpeedLimits.Default,
+		WSHub:      NewWebSocketHub(config.WebSocket),
+		Done:       make(chan bool),
+	}
+	// models can be reloaded at runtime, destroy whatever is loaded at exit
+	defer func() {
+		for _, model := range app.Models {
+			model.Destroy()
+		}
+	}()
+
+	if store, err := OpenEventStore(config.EventDB); err != nil {
+		fmt.Printf("Error opening event store, events are not persisted: %v\n", err)
+	} else {
+		app.EventStore = store
+		defer store.Close()
+	}
+
+	warmUpModels(app.Models)
+	recordModelMetrics(app.Models)
+	recordSignMetrics(app)
+
+	if *headless {
+		app.UI = NewHeadlessUI(app)
+	} else {
+		app.UI = NewDesktopUI(app)
+	}
+	// app.MQTT is set before anything that raises events is started
+	if config.MQTT.Enabled {
+		if publisher, err := StartMQTT(app); err != nil {
+			fmt.Printf("Error starting MQTT: %v\n", err)
+		} else {
+			app.MQTT = publisher
+			defer publisher.Close()
+		}
+	}
+
+	if *headless {
+		go startAllCameras(app)
+	} else {
+		go DetectCameras(app)
 	}
+	app.Workers.Add(1)
+	go runDetectionLoop(app)
+	api := StartAPIServer(app)
+	app.UI.Run()
+	// the deferred teardown needs all of them stopped
+	shutdown(app, api)
+}
 
-	detErr := app.Detector.Run()
-	if detErr != nil {
-		fmt.Printf("Error starting ONNX session: %v", detErr)
+/**
+ * Stop the HTTP API, the streams and the detection loop and wait
+ * for them, only then the models and the event store can be released.
+ * Screen blanking turned off by the sign window is restored.
+ * @param *app, api *http.Server, nil when disabled
+ */
+func shutdown(app *App, api *http.Server) {
+	if api != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := api.Shutdown(ctx); err != nil {
+			fmt.Printf("Error stopping HTTP API: %v\n", err)
+		}
 	}
 
-	SetupUI(app)
-	w.Resize(fyne.NewSize(1280, 720))
-	w.Show()
-	go DetectCameras(app)
-	a.Run()
+	// the kiosk must not be left without screen saver and DPMS
+	releaseSignDisplay(app)
+
+	app.StreamsMu.Lock()
+	close(app.Done)
+	app.StreamsMu.Unlock()
+	for _, stream := range app.activeStreams() {
+		stopStream(app, stream.Device.ID)
+	}
+	app.Workers.Wait()
 }
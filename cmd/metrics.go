@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// latency buckets from 1ms to ~4s
+var latencyBuckets = prometheus.ExponentialBuckets(0.001, 2, 13)
+
+var (
+	metricCaptureFPS = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bip_capture_fps",
+		Help: "Frames per second read from the camera.",
+	}, []string{"camera"})
+	metricFramesCaptured = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bip_frames_captured_total",
+		Help: "Frames read from the camera.",
+	}, []string{"camera"})
+	metricFramesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bip_frames_dropped_total",
+		Help: "Captured frames replaced by a newer one before detection picked them up.",
+	}, []string{"camera"})
+	metricReadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bip_camera_read_failures_total",
+		Help: "Failed frame reads.",
+	}, []string{"camera"})
+	metricCameraUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bip_camera_up",
+		Help: "1 while the camera delivers frames, 0 after a fault or when stopped.",
+	}, []string{"camera"})
+	metricCameraInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bip_camera_info",
+		Help: "Name and device path of the camera ids used as label, always 1.",
+	}, []string{"camera", "name", "path"})
+
+	metricPreprocessSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "bip_preprocess_seconds",
+		Help:    "Time to resize and normalize the frames of one detection run.",
+		Buckets: latencyBuckets,
+	})
+	metricInferenceSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bip_inference_seconds",
+		Help:    "Model run time for one detection run.",
+		Buckets: latencyBuckets,
+	}, []string{"model"})
+	metricPostprocessSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bip_postprocess_seconds",
+		Help:    "Time for tracking, incident detection and drawing of one frame.",
+		Buckets: latencyBuckets,
+	}, []string{"camera"})
+	metricDetections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bip_detections_total",
+		Help: "Detections after zone filtering.",
+	}, []string{"camera", "class"})
+
+	metricSignState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bip_sign_state",
+		Help: "Shown sign state: 0 normal, 1 animal, 2 pedestrian, 3 stopped vehicle, 4 accident, 5 wrong way.",
+	})
+	metricSpeedLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bip_speed_limit_kmh",
+		Help: "Shown speed limit.",
+	})
+	metricModelInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bip_model_info",
+		Help: "Loaded detection models, always 1.",
+	}, []string{"model", "path", "batched"})
+	metricModelsLoaded = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bip_models_loaded",
+		Help: "Number of loaded detection models.",
+	})
+)
+
+/**
+ * Label of a camera in the metrics. Names are not unique,
+ * the id is, see bip_camera_info for the name.
+ * @param device CameraDevice
+ * @return string
+ */
+func cameraLabel(device CameraDevice) string {
+	return strconv.Itoa(device.ID)
+}
+
+/**
+ * Publish the loaded models, replacing the previous ones.
+ * @param models []*Model
+ */
+func recordModelMetrics(models []*Model) {
+	metricModelInfo.Reset()
+	for _, model := range models {
+		metricModelInfo.WithLabelValues(model.Config.Name, model.Config.Path, strconv.FormatBool(model.Batched())).Set(1)
+	}
+	metricModelsLoaded.Set(float64(len(models)))
+}
+
+/**
+ * Publish what the sign shows.
+ * @param *app
+ */
+func recordSignMetrics(app *App) {
+	state, limit := app.currentSign()
+	metricSignState.Set(float64(state))
+	metricSpeedLimit.Set(float64(limit))
+}
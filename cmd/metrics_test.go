@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCameraLabelsAreUnique(t *testing.T) {
+	// two USB cameras of the same model report the same name
+	front := CameraDevice{ID: 0, Name: "USB Camera", Path: "/dev/video0"}
+	back := CameraDevice{ID: 2, Name: "USB Camera", Path: "/dev/video2"}
+	if cameraLabel(front) == cameraLabel(back) {
+		t.Fatalf("both cameras labelled %q", cameraLabel(front))
+	}
+
+	metricCameraUp.WithLabelValues(cameraLabel(front)).Set(1)
+	metricCameraUp.WithLabelValues(cameraLabel(back)).Set(1)
+	t.Cleanup(metricCameraUp.Reset)
+
+	// what stopStream does for the front camera
+	metricCameraUp.WithLabelValues(cameraLabel(front)).Set(0)
+	if up := testutil.ToFloat64(metricCameraUp.WithLabelValues(cameraLabel(back))); up != 1 {
+		t.Errorf("stopping the front camera set the back one to %v", up)
+	}
+}
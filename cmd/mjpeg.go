@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MJPEGConfig struct {
+	// jpeg quality 1-100
+	Quality int `json:"quality"`
+	// highest frame rate sent to a client
+	MaxFPS float64 `json:"maxFps"`
+}
+
+/**
+ * Latest frame of a stream encoded as jpeg, shared by all
+ * clients so every frame is encoded once.
+ */
+type jpegCache struct {
+	mu     sync.Mutex
+	source image.Image
+	data   []byte
+}
+
+/**
+ * Encode the current frame unless it was encoded already.
+ * @param frame *atomic.Value holding an image.Image, quality int
+ * @return []byte, bool false when there is no frame yet
+ */
+func (c *jpegCache) get(frame *atomic.Value, quality int) ([]byte, bool) {
+	img, ok := frame.Load().(image.Image)
+	if !ok {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if img != c.source {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, false
+		}
+		c.source = img
+		c.data = buf.Bytes()
+	}
+	return c.data, true
+}
+
+/**
+ * MJPEG stream of the annotated frames of a camera.
+ */
+func (app *App) handleMJPEG(w http.ResponseWriter, r *http.Request) {
+	stream, ok := app.requestStream(w, r)
+	if !ok {
+		return
+	}
+	app.serveMJPEG(w, r, stream, stream.CurrentImage, &stream.annotatedJPEG)
+}
+
+/**
+ * MJPEG stream of the privacy masked frames without overlay.
+ */
+func (app *App) handleRawMJPEG(w http.ResponseWriter, r *http.Request) {
+	stream, ok := app.requestStream(w, r)
+	if !ok {
+		return
+	}
+	app.serveMJPEG(w, r, stream, stream.RawImage, &stream.rawJPEG)
+}
+
+/**
+ * Write frames as multipart/x-mixed-replace until the client goes
+ * away or the camera is stopped. Frames are only read from the
+ * stream, so slow clients never hold up capture or detection.
+ * @param stream *CameraStream, frame *atomic.Value, cache *jpegCache
+ */
+func (app *App) serveMJPEG(w http.ResponseWriter, r *http.Request, stream *CameraStream, frame *atomic.Value, cache *jpegCache) {
+	config := app.Config.MJPEG
+	quality := config.Quality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	if q, err := strconv.Atoi(r.URL.Query().Get("quality")); err == nil && q > 0 && q <= 100 {
+		quality = q
+	}
+	fps := config.MaxFPS
+	if fps <= 0 {
+		fps = 10
+	}
+	if f, err := strconv.ParseFloat(r.URL.Query().Get("fps"), 64); err == nil && f > 0 && f < fps {
+		fps = f
+	}
+
+	writer := multipart.NewWriter(w)
+	defer writer.Close()
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+writer.Boundary())
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+
+	// per client cache, the quality may differ from the shared one
+	if quality != config.Quality {
+		cache = &jpegCache{}
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / fps))
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stream.stop:
+			return
+		case <-ticker.C:
+		}
+
+		data, ok := cache.get(frame, quality)
+		if !ok || (len(last) > 0 && &data[0] == &last[0]) {
+			continue
+		}
+		last = data
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", "image/jpeg")
+		header.Set("Content-Length", fmt.Sprint(len(data)))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return
+		}
+		if _, err := part.Write(data); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
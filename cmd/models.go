@@ -0,0 +1,439 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yalue/onnxruntime_go"
+)
+
+/**
+ * Detection model run on every frame. Labels map class ids to
+ * names, models with 80 classes and no labels get the COCO labels.
+ */
+type ModelConfig struct {
+	Name          string   `json:"name"`
+	Path          string   `json:"path"`
+	Labels        []string `json:"labels"`
+	ConfThreshold float32  `json:"confThreshold"`
+	IoUThreshold  float32  `json:"iouThreshold"`
+}
+
+/**
+ * Models with a fixed batch size use a session with preallocated
+ * tensors. Models exported with a dynamic batch dimension use a
+ * dynamic session instead, so several images run in one call.
+ */
+type Model struct {
+	Config        ModelConfig
+	Session       *onnxruntime_go.Session[float32]
+	InputTensors  []*onnxruntime_go.Tensor[float32]
+	OutputTensors []*onnxruntime_go.Tensor[float32]
+	Dynamic       *onnxruntime_go.DynamicAdvancedSession
+	Width         int
+	Height        int
+}
+
+/**
+ * Load a model with input and output tensors sized from the model file.
+ * @param config ModelConfig
+ * @return *Model, error
+ */
+func LoadModel(config ModelConfig) (*Model, error) {
+	inputs, outputs, err := onnxruntime_go.GetInputOutputInfo(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error getting input/output info of %s: %v", config.Path, err)
+	}
+	if len(inputs) == 0 || len(inputs[0].Dimensions) != 4 || len(outputs) == 0 {
+		return nil, fmt.Errorf("model %s should have a single NCHW image input", config.Path)
+	}
+	// YOLO output [batch, 4+classes, boxes] with at least one class
+	if dims := outputs[0].Dimensions; len(dims) != 3 || dims[1] <= 4 {
+		return nil, fmt.Errorf("model %s output %v is not [batch, 4+classes, boxes]", config.Path, dims)
+	}
+
+	var model *Model
+	if inputs[0].Dimensions[0] < 0 {
+		model, err = loadDynamicModel(config, inputs, outputs)
+	} else {
+		model, err = loadFixedModel(config, inputs, outputs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	numClasses := int(outputs[0].Dimensions[1]) - 4
+	if len(model.Config.Labels) == 0 && numClasses == len(cocoLabels) {
+		model.Config.Labels = cocoLabels
+	}
+	return model, nil
+}
+
+func loadFixedModel(config ModelConfig, inputs, outputs []onnxruntime_go.InputOutputInfo) (*Model, error) {
+	inputTensors, errs := createTensors[float32](inputs)
+	if errs != nil {
+		return nil, fmt.Errorf("error creating input tensors of %s: %v", config.Path, errs)
+	}
+	outputTensors, errs := createTensors[float32](outputs)
+	if errs != nil {
+		destroyTensors(inputTensors)
+		return nil, fmt.Errorf("error creating output tensors of %s: %v", config.Path, errs)
+	}
+
+	var inputNames []string
+	for i := range inputs {
+		fmt.Printf("\nmodel %s inputname: %v", config.Name, inputs[i])
+		inputNames = append(inputNames, inputs[i].Name)
+	}
+	var outputNames []string
+	for i := range outputs {
+		fmt.Printf("\nmodel %s outputname: %v", config.Name, outputs[i])
+		outputNames = append(outputNames, outputs[i].Name)
+	}
+
+	session, err := onnxruntime_go.NewSession(config.Path, inputNames, outputNames, inputTensors, outputTensors)
+	if err != nil {
+		destroyTensors(inputTensors)
+		destroyTensors(outputTensors)
+		return nil, fmt.Errorf("error creating ONNX session for %s: %v", config.Path, err)
+	}
+
+	return &Model{
+		Config:        config,
+		Session:       session,
+		InputTensors:  inputTensors,
+		OutputTensors: outputTensors,
+		Width:         int(inputs[0].Dimensions[3]),
+		Height:        int(inputs[0].Dimensions[2]),
+	}, nil
+}
+
+func loadDynamicModel(config ModelConfig, inputs, outputs []onnxruntime_go.InputOutputInfo) (*Model, error) {
+	var inputNames []string
+	for i := range inputs {
+		inputNames = append(inputNames, inputs[i].Name)
+	}
+	var outputNames []string
+	for i := range outputs {
+		outputNames = append(outputNames, outputs[i].Name)
+	}
+
+	session, err := onnxruntime_go.NewDynamicAdvancedSession(config.Path, inputNames, outputNames, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dynamic ONNX session for %s: %v", config.Path, err)
+	}
+	fmt.Printf("\nmodel %s has a dynamic batch size", config.Name)
+
+	// dynamic image sizes fall back to the reference size
+	width, height := int(inputs[0].Dimensions[3]), int(inputs[0].Dimensions[2])
+	if width <= 0 || height <= 0 {
+		width, height = InputSize, InputSize
+	}
+
+	return &Model{
+		Config:  config,
+		Dynamic: session,
+		Width:   width,
+		Height:  height,
+	}, nil
+}
+
+/**
+ * Load every configured model, models that fail to load are skipped.
+ * @param configs []ModelConfig
+ * @return []*Model
+ */
+func LoadModels(configs []ModelConfig) []*Model {
+	var models []*Model
+	for _, config := range configs {
+		model, err := LoadModel(config)
+		if err != nil {
+			fmt.Printf("Error loading model %s: %v\n", config.Name, err)
+			continue
+		}
+		models = append(models, model)
+	}
+	fmt.Printf("\nonnxruntime version: %v\n", onnxruntime_go.GetVersion())
+	return models
+}
+
+/**
+ * Run fixed size sessions once so that the first frame is not slow.
+ * @param models []*Model
+ */
+func warmUpModels(models []*Model) {
+	for _, model := range models {
+		if model.Session == nil {
+			continue
+		}
+		if detErr := model.Session.Run(); detErr != nil {
+			fmt.Printf("Error starting ONNX session %s: %v\n", model.Config.Name, detErr)
+		}
+	}
+}
+
+/**
+ * Read the model configs from ConfigPath again, load them and swap
+ * them in between frames. The old models stay in use when the config
+ * can not be read or none of the new models load.
+ * @param *app
+ * @return error
+ */
+func (app *App) reloadModels() error {
+	config, err := LoadConfig(ConfigPath)
+	if err != nil {
+		return fmt.Errorf("%v, keeping the current models", err)
+	}
+	models := LoadModels(config.Models)
+	if len(models) == 0 && len(config.Models) > 0 {
+		return fmt.Errorf("no model could be loaded, keeping the current models")
+	}
+	warmUpModels(models)
+
+	app.ConfigMu.Lock()
+	app.Config.Models = config.Models
+	app.ConfigMu.Unlock()
+
+	app.ModelsMu.Lock()
+	old := app.Models
+	app.Models = models
+	app.ModelsMu.Unlock()
+
+	for _, model := range old {
+		model.Destroy()
+	}
+	recordModelMetrics(models)
+
+	names := make([]string, len(models))
+	for i, model := range models {
+		names[i] = model.Config.Name
+	}
+	app.emitEvent(Event{
+		Type:     EventModelReload,
+		CameraID: -1,
+		Message:  fmt.Sprintf("loaded %d of %d models: %s", len(models), len(config.Models), strings.Join(names, ", ")),
+	})
+	return nil
+}
+
+/**
+ * Number of loaded models, safe to call while models are reloaded.
+ * @return int
+ */
+func (app *App) modelCount() int {
+	app.ModelsMu.RLock()
+	defer app.ModelsMu.RUnlock()
+	return len(app.Models)
+}
+
+func (m *Model) Destroy() {
+	if m.Session != nil {
+		m.Session.Destroy()
+	}
+	if m.Dynamic != nil {
+		m.Dynamic.Destroy()
+	}
+	destroyTensors(m.InputTensors)
+	destroyTensors(m.OutputTensors)
+}
+
+/**
+ * Whether the model can run several images in one call.
+ */
+func (m *Model) Batched() bool {
+	return m.Dynamic != nil
+}
+
+/**
+ * Run the model on preprocessed images. Dynamic batch models run all
+ * images at once, fixed size models run them one after another.
+ * @param inputs [][]float32 one CHW slice per image
+ * @return outputs per image, shape of a single image output, error
+ */
+func (m *Model) RunBatch(inputs [][]float32) ([][]float32, onnxruntime_go.Shape, error) {
+	if len(inputs) == 0 {
+		return nil, nil, nil
+	}
+	if m.Dynamic != nil {
+		return m.runDynamic(inputs)
+	}
+
+	var outputs [][]float32
+	for _, input := range inputs {
+		copy(m.InputTensors[0].GetData(), input)
+		if err := m.Session.Run(); err != nil {
+			return nil, nil, fmt.Errorf("error running model %s: %v", m.Config.Name, err)
+		}
+		output := m.OutputTensors[0].GetData()
+		outputs = append(outputs, append([]float32(nil), output...))
+	}
+	return outputs, m.OutputTensors[0].GetShape(), nil
+}
+
+func (m *Model) runDynamic(inputs [][]float32) ([][]float32, onnxruntime_go.Shape, error) {
+	imageSize := 3 * m.Width * m.Height
+	data := make([]float32, 0, len(inputs)*imageSize)
+	for _, input := range inputs {
+		data = append(data, input...)
+	}
+
+	input, err := onnxruntime_go.NewTensor(onnxruntime_go.NewShape(int64(len(inputs)), 3, int64(m.Height), int64(m.Width)), data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating batch tensor for %s: %v", m.Config.Name, err)
+	}
+	defer input.Destroy()
+
+	// output is allocated by onnxruntime
+	outputs := []onnxruntime_go.Value{nil}
+	if err := m.Dynamic.Run([]onnxruntime_go.Value{input}, outputs); err != nil {
+		return nil, nil, fmt.Errorf("error running model %s: %v", m.Config.Name, err)
+	}
+	defer outputs[0].Destroy()
+
+	output, ok := outputs[0].(*onnxruntime_go.Tensor[float32])
+	if !ok {
+		return nil, nil, fmt.Errorf("model %s output is not a float tensor", m.Config.Name)
+	}
+
+	shape := output.GetShape()
+	itemShape := onnxruntime_go.NewShape(1, shape[1], shape[2])
+	itemSize := int(itemShape.FlattenedSize())
+	outputData := output.GetData()
+
+	results := make([][]float32, len(inputs))
+	for i := range results {
+		results[i] = append([]float32(nil), outputData[i*itemSize:(i+1)*itemSize]...)
+	}
+	return results, itemShape, nil
+}
+
+func destroyTensors(tensors []*onnxruntime_go.Tensor[float32]) {
+	for _, tensor := range tensors {
+		tensor.Destroy()
+	}
+}
+
+func (m *Model) className(classID int) string {
+	if classID < len(m.Config.Labels) {
+		return m.Config.Labels[classID]
+	}
+	return fmt.Sprintf("Class %d", classID)
+}
+
+/**
+ * Frame of one camera with the regions to run the models on,
+ * more than one region means tiled inference.
+ */
+type FrameInput struct {
+	Image   image.Image
+	Regions []image.Rectangle // in frame pixels
+}
+
+type regionRef struct {
+	frame  int
+	region image.Rectangle
+}
+
+/**
+ * Run all models on a set of frames. Every frame region is
+ * preprocessed once per distinct input size and the models run
+ * concurrently, each getting all regions of all frames in one batch.
+ * The detections of a frame are merged into one slice where
+ * Detection.Model tells which model produced each box. With more
+ * than one region the boxes are mapped back to the frame and merged with NMS.
+ * A model that fails is left out of the frame, the others still count.
+ * @param models []*Model, frames []FrameInput
+ * @return detections per frame, error when every model failed
+ */
+func runModels(models []*Model, frames []FrameInput) ([][]Detection, error) {
+	var refs []regionRef
+	var crops []image.Image
+	for i, frame := range frames {
+		for _, region := range frame.Regions {
+			refs = append(refs, regionRef{frame: i, region: region})
+			crops = append(crops, cropImage(frame.Image, region))
+		}
+	}
+
+	preprocessStart := time.Now()
+	inputs := make(map[image.Point][][]float32)
+	for _, model := range models {
+		size := image.Pt(model.Width, model.Height)
+		if _, ok := inputs[size]; ok {
+			continue
+		}
+		for _, crop := range crops {
+			data, err := preprocessImage(crop, model.Width, model.Height)
+			if err != nil {
+				return nil, err
+			}
+			inputs[size] = append(inputs[size], data)
+		}
+	}
+	metricPreprocessSeconds.Observe(time.Since(preprocessStart).Seconds())
+
+	var wg sync.WaitGroup
+	// results[model][frame]
+	results := make([][][]Detection, len(models))
+	errs := make([]error, len(models))
+	for i, model := range models {
+		wg.Add(1)
+		go func(i int, model *Model) {
+			defer wg.Done()
+			inferenceStart := time.Now()
+			outputs, shape, err := model.RunBatch(inputs[image.Pt(model.Width, model.Height)])
+			metricInferenceSeconds.WithLabelValues(model.Config.Name).Observe(time.Since(inferenceStart).Seconds())
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = make([][]Detection, len(frames))
+			for k, output := range outputs {
+				ref := refs[k]
+				detections := model.parseOutput(output, shape)
+				detections = mapToFrame(detections, ref.region, frames[ref.frame].Image.Bounds())
+				results[i][ref.frame] = append(results[i][ref.frame], detections...)
+			}
+			for f := range frames {
+				if len(frames[f].Regions) > 1 {
+					results[i][f] = applyNMS(results[i][f], model.Config.IoUThreshold)
+				}
+			}
+		}(i, model)
+	}
+	wg.Wait()
+
+	merged := make([][]Detection, len(frames))
+	failed := 0
+	for i := range models {
+		if errs[i] != nil {
+			fmt.Printf("Skipping model %s for this frame: %v\n", models[i].Config.Name, errs[i])
+			failed++
+			continue
+		}
+		for f := range frames {
+			merged[f] = append(merged[f], results[i][f]...)
+		}
+	}
+	if failed > 0 && failed == len(models) {
+		return nil, fmt.Errorf("all %d models failed, last error: %v", failed, errs[len(errs)-1])
+	}
+	return merged, nil
+}
+
+var cocoLabels = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat",
+	"traffic light", "fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat",
+	"dog", "horse", "sheep", "cow", "elephant", "bear", "zebra", "giraffe", "backpack",
+	"umbrella", "handbag", "tie", "suitcase", "frisbee", "skis", "snowboard", "sports ball",
+	"kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket",
+	"bottle", "wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple",
+	"sandwich", "orange", "broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair",
+	"couch", "potted plant", "bed", "dining table", "toilet", "tv", "laptop", "mouse",
+	"remote", "keyboard", "cell phone", "microwave", "oven", "toaster", "sink",
+	"refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier",
+	"toothbrush",
+}
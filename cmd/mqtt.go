@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	mqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+type MQTTConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Broker   string `json:"broker"`
+	ClientID string `json:"clientId"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// topics are <prefix>/state, /events, /heartbeat, /status and /command
+	TopicPrefix string `json:"topicPrefix"`
+	QoS         byte   `json:"qos"`
+	// seconds between heartbeats
+	Heartbeat float64 `json:"heartbeatSeconds"`
+
+	// run a local broker, for testing without the backbone. Only the
+	// configured user may connect to it, without one only local clients.
+	EmbeddedBroker bool   `json:"embeddedBroker"`
+	EmbeddedAddr   string `json:"embeddedAddr"`
+}
+
+type heartbeatMessage struct {
+	Time       time.Time `json:"time"`
+	Uptime     float64   `json:"uptimeSeconds"`
+	SignState  string    `json:"signState"`
+	SpeedLimit int       `json:"speedLimit"`
+	Cameras    []int     `json:"cameras"`
+}
+
+/**
+ * Publishes sign state, events and heartbeats to an MQTT broker and
+ * runs commands received on the command topic. The retained status
+ * topic is "online" while connected, the last will sets it "offline".
+ */
+type MQTTPublisher struct {
+	Config MQTTConfig
+
+	app    *App
+	client paho.Client
+	broker *mqtt.Server
+	stop   chan bool
+}
+
+func (p *MQTTPublisher) topic(name string) string {
+	return p.Config.TopicPrefix + "/" + name
+}
+
+/**
+ * Connect to the broker, starting the embedded one first if configured.
+ * Connecting is retried in the background, so the app starts without a broker.
+ * @param *app
+ * @return *MQTTPublisher, error
+ */
+func StartMQTT(app *App) (*MQTTPublisher, error) {
+	config := app.Config.MQTT
+	p := &MQTTPublisher{Config: config, app: app, stop: make(chan bool)}
+
+	if config.EmbeddedBroker {
+		broker := mqtt.New(&mqtt.Options{InlineClient: true})
+		if err := broker.AddHook(new(auth.Hook), &auth.Options{Ledger: embeddedBrokerLedger(config)}); err != nil {
+			return nil, fmt.Errorf("failed to configure embedded broker: %v", err)
+		}
+		tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: config.EmbeddedAddr})
+		if err := broker.AddListener(tcp); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %v", config.EmbeddedAddr, err)
+		}
+		go func() {
+			if err := broker.Serve(); err != nil {
+				fmt.Printf("Error running embedded MQTT broker: %v\n", err)
+			}
+		}()
+		p.broker = broker
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetWill(p.topic("status"), "offline", config.QoS, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			fmt.Printf("MQTT connection lost: %v\n", err)
+		})
+	p.client = paho.NewClient(opts)
+	p.client.Connect()
+
+	go p.heartbeat()
+	return p, nil
+}
+
+/**
+ * Access rules of the embedded broker. With a username and password
+ * only that user may connect, otherwise only clients on this host.
+ * Either way clients only reach the topics under TopicPrefix.
+ * @param MQTTConfig
+ * @return *auth.Ledger
+ */
+func embeddedBrokerLedger(config MQTTConfig) *auth.Ledger {
+	ledger := &auth.Ledger{
+		ACL: auth.ACLRules{{
+			Filters: auth.Filters{
+				auth.RString(config.TopicPrefix + "/#"): auth.ReadWrite,
+				"#":                                     auth.Deny,
+			},
+		}},
+	}
+	if config.Username != "" && config.Password != "" {
+		ledger.Users = auth.Users{
+			config.Username: {Username: auth.RString(config.Username), Password: auth.RString(config.Password)},
+		}
+	} else {
+		ledger.Auth = auth.AuthRules{
+			{Remote: "127.0.0.1:*", Allow: true},
+			{Remote: "[::1]:*", Allow: true},
+		}
+	}
+	return ledger
+}
+
+/**
+ * Announce the app and resubscribe after every (re)connect.
+ */
+func (p *MQTTPublisher) onConnect(client paho.Client) {
+	fmt.Printf("MQTT connected to %s\n", p.Config.Broker)
+	p.publish("status", true, []byte("online"))
+	p.PublishState()
+
+	client.Subscribe(p.topic("command"), p.Config.QoS, func(_ paho.Client, msg paho.Message) {
+		if err := p.app.handleCommand(msg.Payload()); err != nil {
+			fmt.Printf("Error running MQTT command: %v\n", err)
+		}
+	})
+}
+
+func (p *MQTTPublisher) publish(name string, retained bool, payload []byte) {
+	token := p.client.Publish(p.topic(name), p.Config.QoS, retained, payload)
+	go func() {
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			fmt.Printf("Error publishing MQTT %s: %v\n", name, token.Error())
+		}
+	}()
+}
+
+func (p *MQTTPublisher) publishJSON(name string, retained bool, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("Error encoding MQTT %s: %v\n", name, err)
+		return
+	}
+	p.publish(name, retained, payload)
+}
+
+/**
+ * Publish what the sign shows as the retained state.
+ */
+func (p *MQTTPublisher) PublishState() {
+	p.publishJSON("state", true, struct {
+		signStateMessage
+		Time time.Time `json:"time"`
+	}{
+		signStateMessage: p.app.signStateMessage(),
+		Time:             time.Now(),
+	})
+}
+
+func (p *MQTTPublisher) PublishEvent(event Event) {
+	p.publishJSON("events", false, event)
+}
+
+func (p *MQTTPublisher) heartbeat() {
+	interval := time.Duration(p.Config.Heartbeat * float64(time.Second))
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			sign := p.app.signStateMessage()
+			message := heartbeatMessage{
+				Time:       now,
+				Uptime:     now.Sub(appStartTime).Seconds(),
+				SignState:  sign.State,
+				SpeedLimit: sign.SpeedLimit,
+				Cameras:    []int{},
+			}
+			for _, stream := range p.app.activeStreams() {
+				message.Cameras = append(message.Cameras, stream.Device.ID)
+			}
+			p.publishJSON("heartbeat", false, message)
+		}
+	}
+}
+
+/**
+ * Go offline cleanly, the last will only covers crashes.
+ */
+func (p *MQTTPublisher) Close() {
+	close(p.stop)
+	if p.client.IsConnected() {
+		p.client.Publish(p.topic("status"), p.Config.QoS, true, "offline").WaitTimeout(2 * time.Second)
+	}
+	p.client.Disconnect(250)
+	if p.broker != nil {
+		p.broker.Close()
+	}
+}
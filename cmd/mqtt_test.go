@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+/**
+ * App publishing to an embedded broker, and a second client
+ * subscribed to all of its topics.
+ */
+func startTestMQTT(t *testing.T) (*App, *MQTTPublisher, chan paho.Message) {
+	t.Helper()
+	addr := freeAddr(t)
+	config := DefaultConfig()
+	config.MQTT = MQTTConfig{
+		Enabled:        true,
+		Broker:         "tcp://" + addr,
+		ClientID:       "sign-test",
+		Username:       "sign",
+		Password:       "secret",
+		TopicPrefix:    "test",
+		QoS:            1,
+		Heartbeat:      60,
+		EmbeddedBroker: true,
+		EmbeddedAddr:   addr,
+	}
+	app := newTestApp(config)
+
+	publisher, err := StartMQTT(app)
+	if err != nil {
+		t.Fatalf("StartMQTT() error: %v", err)
+	}
+	app.MQTT = publisher
+	t.Cleanup(publisher.Close)
+	waitFor(t, "publisher connection", publisher.client.IsConnectionOpen)
+
+	messages := make(chan paho.Message, 100)
+	opts := paho.NewClientOptions().
+		AddBroker(config.MQTT.Broker).
+		SetClientID("dashboard").
+		SetUsername("sign").
+		SetPassword("secret")
+	subscriber := paho.NewClient(opts)
+	if token := subscriber.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscriber connect: %v", token.Error())
+	}
+	t.Cleanup(func() { subscriber.Disconnect(100) })
+	token := subscriber.Subscribe("test/#", 1, func(_ paho.Client, msg paho.Message) { messages <- msg })
+	if !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+		t.Fatalf("subscribe: %v", token.Error())
+	}
+	return app, publisher, messages
+}
+
+/**
+ * Wait for a message on a topic, skipping the others.
+ */
+func waitForMessage(t *testing.T, messages chan paho.Message, topic string, match func(paho.Message) bool) paho.Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			if msg.Topic() == topic && match(msg) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("no matching message on %s", topic)
+			return nil
+		}
+	}
+}
+
+func TestMQTTRetainedState(t *testing.T) {
+	_, _, messages := startTestMQTT(t)
+
+	status := waitForMessage(t, messages, "test/status", func(msg paho.Message) bool { return true })
+	if string(status.Payload()) != "online" || !status.Retained() {
+		t.Errorf("status = %q retained %v, want retained online", status.Payload(), status.Retained())
+	}
+
+	state := waitForMessage(t, messages, "test/state", func(msg paho.Message) bool { return true })
+	var message signStateMessage
+	if err := json.Unmarshal(state.Payload(), &message); err != nil {
+		t.Fatalf("invalid state payload %q: %v", state.Payload(), err)
+	}
+	if !state.Retained() || message.State != SignStateNormal.String() || message.SpeedLimit != 100 {
+		t.Errorf("state = %+v retained %v, want retained normal at 100 km/h", message, state.Retained())
+	}
+}
+
+func TestMQTTLastWill(t *testing.T) {
+	_, publisher, messages := startTestMQTT(t)
+	waitForMessage(t, messages, "test/status", func(msg paho.Message) bool { return string(msg.Payload()) == "online" })
+
+	// drop the connection without a DISCONNECT, like a crash would
+	client, ok := publisher.broker.Clients.Get(publisher.Config.ClientID)
+	if !ok {
+		t.Fatalf("publisher is not connected to the broker")
+	}
+	client.Stop(errors.New("simulated crash"))
+
+	waitForMessage(t, messages, "test/status", func(msg paho.Message) bool { return string(msg.Payload()) == "offline" })
+}
+
+func TestMQTTCommands(t *testing.T) {
+	app, _, messages := startTestMQTT(t)
+	waitForMessage(t, messages, "test/status", func(msg paho.Message) bool { return true })
+
+	publish := func(payload string) {
+		t.Helper()
+		client := paho.NewClient(paho.NewClientOptions().
+			AddBroker(app.Config.MQTT.Broker).
+			SetClientID(fmt.Sprintf("tmc-%d", time.Now().UnixNano())).
+			SetUsername("sign").
+			SetPassword("secret"))
+		if token := client.Connect(); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("connect: %v", token.Error())
+		}
+		defer client.Disconnect(100)
+		if token := client.Publish("test/command", 1, false, payload); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("publish: %v", token.Error())
+		}
+	}
+
+	publish(`{"command": "override", "speedLimit": 60, "source": "tmc", "reason": "roadworks", "durationMinutes": 5}`)
+	waitForMessage(t, messages, "test/state", func(msg paho.Message) bool {
+		var message signStateMessage
+		return json.Unmarshal(msg.Payload(), &message) == nil && message.SpeedLimit == 60
+	})
+	if override := app.activeOverride(); override == nil || override.Source != "tmc" {
+		t.Errorf("override = %+v, want the one set by tmc", override)
+	}
+
+	publish(`{"command": "clear_override", "source": "tmc"}`)
+	waitForMessage(t, messages, "test/state", func(msg paho.Message) bool {
+		var message signStateMessage
+		return json.Unmarshal(msg.Payload(), &message) == nil && message.SpeedLimit == 100
+	})
+	if override := app.activeOverride(); override != nil {
+		t.Errorf("override %+v still active after clear_override", override)
+	}
+}
+
+func TestMQTTEmbeddedBrokerAuth(t *testing.T) {
+	app, _, _ := startTestMQTT(t)
+
+	var connected atomic.Bool
+	client := paho.NewClient(paho.NewClientOptions().
+		AddBroker(app.Config.MQTT.Broker).
+		SetClientID("intruder").
+		SetUsername("sign").
+		SetPassword("wrong").
+		SetOnConnectHandler(func(paho.Client) { connected.Store(true) }))
+	token := client.Connect()
+	token.WaitTimeout(5 * time.Second)
+	if token.Error() == nil || connected.Load() {
+		client.Disconnect(100)
+		t.Errorf("client with a wrong password was accepted")
+	}
+}
+
+func TestEmbeddedBrokerLedger(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MQTTConfig
+		users    int
+		authRule int
+	}{
+		{"credentials", MQTTConfig{TopicPrefix: "sign", Username: "sign", Password: "secret"}, 1, 0},
+		{"local clients only", MQTTConfig{TopicPrefix: "sign"}, 0, 2},
+		{"username without password", MQTTConfig{TopicPrefix: "sign", Username: "sign"}, 0, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ledger := embeddedBrokerLedger(test.config)
+			if len(ledger.Users) != test.users || len(ledger.Auth) != test.authRule {
+				t.Errorf("got %d users and %d auth rules, want %d and %d", len(ledger.Users), len(ledger.Auth), test.users, test.authRule)
+			}
+			if len(ledger.ACL) != 1 || ledger.ACL[0].Filters["sign/#"] != auth.ReadWrite {
+				t.Errorf("ACL %+v does not grant the topic prefix", ledger.ACL)
+			}
+		})
+	}
+}
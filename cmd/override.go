@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// used when an override request has no duration
+	defaultOverrideDuration = time.Hour
+	// state select entry that leaves the state to the detections
+	automaticState = "automatic"
+)
+
+/**
+ * Manual override of the sign set by an operator, e.g. for roadworks.
+ * While active it takes precedence over the detections, an empty
+ * State or a zero SpeedLimit keeps that part automatic.
+ */
+type Override struct {
+	State      string    `json:"state,omitempty"`
+	SpeedLimit int       `json:"speedLimit,omitempty"`
+	Source     string    `json:"source"`
+	Reason     string    `json:"reason"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+
+	state SignState
+	timer *time.Timer
+}
+
+/**
+ * Sign state by its name as shown by SignState.String().
+ * @param name string
+ * @return SignState, error
+ */
+func parseSignState(name string) (SignState, error) {
+	for state := SignStateNormal; state <= SignStateWrongWay; state++ {
+		if state.String() == name {
+			return state, nil
+		}
+	}
+	return SignStateNormal, fmt.Errorf("unknown sign state %q", name)
+}
+
+/**
+ * Activate an override, replacing the current one, and expire it
+ * automatically.
+ * @param *app, Override with Source and Reason, duration time.Duration
+ * @return Override as applied, error
+ */
+func setOverride(app *App, override Override, duration time.Duration) (Override, error) {
+	if override.Source == "" || override.Reason == "" {
+		return Override{}, fmt.Errorf("override needs a source and a reason")
+	}
+	if override.State == "" && override.SpeedLimit <= 0 {
+		return Override{}, fmt.Errorf("override needs a state or a speed limit")
+	}
+	if override.State != "" {
+		state, err := parseSignState(override.State)
+		if err != nil {
+			return Override{}, err
+		}
+		override.state = state
+	}
+	if duration <= 0 {
+		duration = defaultOverrideDuration
+	}
+	override.Created = time.Now()
+	override.Expires = override.Created.Add(duration)
+
+	app.OverrideMu.Lock()
+	if app.Override != nil {
+		app.Override.timer.Stop()
+	}
+	active := &override
+	active.timer = time.AfterFunc(duration, func() { expireOverride(app, active) })
+	app.Override = active
+	app.OverrideMu.Unlock()
+
+	app.emitEvent(Event{
+		Type:     EventOverride,
+		CameraID: -1,
+		Message:  fmt.Sprintf("set by %s until %s: %s (%s)", override.Source, override.Expires.Format(time.TimeOnly), override.describe(), override.Reason),
+	})
+	updateSignState(app)
+	updateOverrideUI(app)
+	return override, nil
+}
+
+/**
+ * Return to automatic mode.
+ * @param *app, source string who cleared it
+ * @return error when no override is active
+ */
+func clearOverride(app *App, source string) error {
+	app.OverrideMu.Lock()
+	override := app.Override
+	app.Override = nil
+	app.OverrideMu.Unlock()
+
+	if override == nil {
+		return fmt.Errorf("no override active")
+	}
+	override.timer.Stop()
+
+	app.emitEvent(Event{
+		Type:     EventOverride,
+		CameraID: -1,
+		Message:  fmt.Sprintf("cleared by %s: %s", source, override.describe()),
+	})
+	updateSignState(app)
+	updateOverrideUI(app)
+	return nil
+}
+
+func expireOverride(app *App, override *Override) {
+	app.OverrideMu.Lock()
+	if app.Override != override {
+		// replaced or cleared in the meantime
+		app.OverrideMu.Unlock()
+		return
+	}
+	app.Override = nil
+	app.OverrideMu.Unlock()
+
+	app.emitEvent(Event{
+		Type:     EventOverride,
+		CameraID: -1,
+		Message:  fmt.Sprintf("expired, back to automatic: %s", override.describe()),
+	})
+	updateSignState(app)
+	updateOverrideUI(app)
+}
+
+/**
+ * The active override.
+ * @return *Override copy, nil in automatic mode
+ */
+func (app *App) activeOverride() *Override {
+	app.OverrideMu.Lock()
+	defer app.OverrideMu.Unlock()
+	if app.Override == nil {
+		return nil
+	}
+	override := *app.Override
+	return &override
+}
+
+func (o Override) describe() string {
+	text := ""
+	if o.State != "" {
+		text = o.State
+	}
+	if o.SpeedLimit > 0 {
+		if text != "" {
+			text += ", "
+		}
+		text += fmt.Sprintf("%d km/h", o.SpeedLimit)
+	}
+	return text
+}
+
+/**
+ * Show the override. The signs are redrawn as well, a forced
+ * limit can change the displayed limit without a state change.
+ * @param *app
+ */
+func updateOverrideUI(app *App) {
+	app.UI.ShowSigns(app.currentSign())
+	app.UI.ShowOverride(app.activeOverride())
+}
+
+/**
+ * Controls to force the sign from the Debug tab.
+ * @param *app
+ * @return fyne.CanvasObject
+ */
+func OverrideControls(app *App) fyne.CanvasObject {
+	states := []string{automaticState}
+	for state := SignStateNormal; state <= SignStateWrongWay; state++ {
+		states = append(states, state.String())
+	}
+	stateSelect := widget.NewSelect(states, nil)
+	stateSelect.SetSelected(automaticState)
+
+	limitEntry := widget.NewEntry()
+	limitEntry.SetPlaceHolder("Speed limit km/h (automatic)")
+	reasonEntry := widget.NewEntry()
+	reasonEntry.SetPlaceHolder("Reason")
+	durationEntry := widget.NewEntry()
+	durationEntry.SetPlaceHolder("Minutes (60)")
+
+	app.OverrideLabel = widget.NewLabel("Automatic")
+
+	applyBtn := widget.NewButton("Override", func() {
+		override := Override{Source: "operator", Reason: reasonEntry.Text}
+		if stateSelect.Selected != automaticState {
+			override.State = stateSelect.Selected
+		}
+		if limitEntry.Text != "" {
+			limit, err := strconv.Atoi(limitEntry.Text)
+			if err != nil || limit <= 0 {
+				app.StatusLabel.SetText("Enter the speed limit in km/h")
+				return
+			}
+			override.SpeedLimit = limit
+		}
+		var duration time.Duration
+		if durationEntry.Text != "" {
+			minutes, err := strconv.ParseFloat(durationEntry.Text, 64)
+			if err != nil || minutes <= 0 {
+				app.StatusLabel.SetText("Enter the duration in minutes")
+				return
+			}
+			duration = time.Duration(minutes * float64(time.Minute))
+		}
+		if _, err := setOverride(app, override, duration); err != nil {
+			app.StatusLabel.SetText(err.Error())
+		}
+	})
+
+	clearBtn := widget.NewButton("Automatic", func() {
+		if err := clearOverride(app, "operator"); err != nil {
+			app.StatusLabel.SetText(err.Error())
+		}
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("Manual Override:"),
+		stateSelect,
+		limitEntry,
+		reasonEntry,
+		durationEntry,
+		container.NewGridWithColumns(2, applyBtn, clearBtn),
+		app.OverrideLabel,
+	)
+}
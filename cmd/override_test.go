@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverrideExpires(t *testing.T) {
+	app := newTestApp(nil)
+	override := Override{State: "accident", SpeedLimit: 60, Source: "operator", Reason: "test"}
+	if _, err := setOverride(app, override, 50*time.Millisecond); err != nil {
+		t.Fatalf("setOverride() error: %v", err)
+	}
+	if state, limit := app.currentSign(); state != SignStateAccident || limit != 60 {
+		t.Errorf("sign = %v at %d km/h, want the override", state, limit)
+	}
+
+	waitFor(t, "override expiry", func() bool { return app.activeOverride() == nil })
+	if state, limit := app.currentSign(); state != SignStateNormal || limit != app.Config.SpeedLimits.Default {
+		t.Errorf("sign = %v at %d km/h after expiry, want automatic", state, limit)
+	}
+}
+
+func TestOverrideReplacedDoesNotExpire(t *testing.T) {
+	app := newTestApp(nil)
+	first := Override{SpeedLimit: 60, Source: "operator", Reason: "first"}
+	if _, err := setOverride(app, first, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	second := Override{SpeedLimit: 80, Source: "operator", Reason: "second"}
+	if _, err := setOverride(app, second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { clearOverride(app, "test") })
+
+	time.Sleep(150 * time.Millisecond)
+	if override := app.activeOverride(); override == nil || override.Reason != "second" {
+		t.Errorf("override = %+v, want the second one still active", override)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+type PrivacyMode string
+
+const (
+	PrivacyBlur     PrivacyMode = "blur"
+	PrivacyPixelate PrivacyMode = "pixelate"
+)
+
+// size of the pixelation blocks, pixels
+const pixelBlockSize = 16
+
+type PrivacyConfig struct {
+	Enabled bool        `json:"enabled"`
+	Mode    PrivacyMode `json:"mode"`
+	// name of a face/plate detector in Models, all its detections are masked
+	Model string `json:"model"`
+	// classes masked from any model
+	Classes []string `json:"classes"`
+	// added around detected boxes, fraction of the box size
+	Padding float32 `json:"padding"`
+}
+
+/**
+ * Split off the detections that are only used for masking, so that
+ * faces and plates are not tracked as road users.
+ * @param PrivacyConfig, results []Detection
+ * @return others []Detection, private []Detection
+ */
+func splitPrivacyDetections(config PrivacyConfig, results []Detection) ([]Detection, []Detection) {
+	if !config.Enabled {
+		return results, nil
+	}
+
+	var others, private []Detection
+	for _, det := range results {
+		if (config.Model != "" && det.Model == config.Model) || containsString(config.Classes, det.ClassName) {
+			private = append(private, det)
+		} else {
+			others = append(others, det)
+		}
+	}
+	return others, private
+}
+
+/**
+ * Blur or pixelate the detected faces and plates and the static
+ * privacy masks of the camera. Inference runs on the original frame,
+ * this is applied to every frame that is stored or streamed.
+ * An unmasked frame must never leave the device, so callers drop
+ * the frame when masking fails.
+ * @param img image.Image, config PrivacyConfig, private []Detection, masks [][]Point normalized polygons
+ * @return image.Image, error
+ */
+func maskPrivacy(img image.Image, config PrivacyConfig, private []Detection, masks [][]Point) (image.Image, error) {
+	if !config.Enabled || (len(private) == 0 && len(masks) == 0) {
+		return img, nil
+	}
+
+	mat, err := gocv.ImageToMatRGBA(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert frame for privacy masking: %v", err)
+	}
+	defer mat.Close()
+
+	width := float32(mat.Cols())
+	height := float32(mat.Rows())
+	scaleX := width / InputSize
+	scaleY := height / InputSize
+
+	mask := gocv.Zeros(mat.Rows(), mat.Cols(), gocv.MatTypeCV8UC1)
+	defer mask.Close()
+	white := color.RGBA{255, 255, 255, 255}
+
+	for _, det := range private {
+		padX := (det.BBox.XMax - det.BBox.XMin) * config.Padding
+		padY := (det.BBox.YMax - det.BBox.YMin) * config.Padding
+		rect := image.Rect(
+			int((det.BBox.XMin-padX)*scaleX), int((det.BBox.YMin-padY)*scaleY),
+			int((det.BBox.XMax+padX)*scaleX), int((det.BBox.YMax+padY)*scaleY),
+		)
+		gocv.Rectangle(&mask, rect, white, -1)
+	}
+
+	for _, polygon := range masks {
+		if len(polygon) < 3 {
+			continue
+		}
+		points := make([]image.Point, len(polygon))
+		for i, p := range polygon {
+			points[i] = image.Pt(int(p.X*width), int(p.Y*height))
+		}
+		pv := gocv.NewPointsVectorFromPoints([][]image.Point{points})
+		gocv.FillPoly(&mask, pv, white)
+		pv.Close()
+	}
+
+	masked := gocv.NewMat()
+	defer masked.Close()
+	switch config.Mode {
+	case PrivacyPixelate:
+		small := gocv.NewMat()
+		blocks := image.Pt((mat.Cols()+pixelBlockSize-1)/pixelBlockSize, (mat.Rows()+pixelBlockSize-1)/pixelBlockSize)
+		gocv.Resize(mat, &small, blocks, 0, 0, gocv.InterpolationLinear)
+		gocv.Resize(small, &masked, image.Pt(mat.Cols(), mat.Rows()), 0, 0, gocv.InterpolationNearestNeighbor)
+		small.Close()
+	default:
+		// kernel relative to the frame so that faces stay unreadable at any resolution
+		k := mat.Cols()/20 | 1
+		if k < 15 {
+			k = 15
+		}
+		gocv.GaussianBlur(mat, &masked, image.Pt(k, k), 0, 0, gocv.BorderDefault)
+	}
+	masked.CopyToWithMask(&mat, mask)
+
+	out, err := mat.ToImage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert masked frame: %v", err)
+	}
+	return out, nil
+}
+
+/**
+ * Static privacy masks configured for a camera.
+ * @param CameraDevice
+ * @return [][]Point
+ */
+func (app *App) cameraPrivacyMasks(device CameraDevice) [][]Point {
+	if camera, ok := app.cameraConfig(device); ok {
+		return camera.PrivacyMasks
+	}
+	return nil
+}
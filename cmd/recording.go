@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+type RecordingConfig struct {
+	Enabled bool   `json:"enabled"`
+	Dir     string `json:"dir"`
+	// seconds kept before and after the triggering state change
+	PreRoll  float64 `json:"preRollSeconds"`
+	PostRoll float64 `json:"postRollSeconds"`
+	// frames per second kept in the buffer and written to the clip
+	FPS float64 `json:"fps"`
+	// sign states that start a clip, as shown by SignState.String()
+	TriggerStates []string `json:"triggerStates"`
+	// oldest clips are removed when the directory grows past this size
+	MaxDiskMB int64 `json:"maxDiskMB"`
+	// clips older than this are removed, 0 keeps them forever
+	RetentionDays int `json:"retentionDays"`
+}
+
+/**
+ * A buffered frame, stored as jpeg so that the pre-roll of
+ * several cameras fits in memory.
+ */
+type recordedFrame struct {
+	Time       time.Time
+	JPEG       []byte
+	Detections []Detection
+}
+
+type clipFrame struct {
+	Time       time.Time   `json:"time"`
+	Detections []Detection `json:"detections"`
+}
+
+/**
+ * JSON sidecar written next to every clip.
+ */
+type clipMetadata struct {
+	Camera    string      `json:"camera"`
+	CameraID  int         `json:"cameraId"`
+	State     string      `json:"state"`
+	Triggered time.Time   `json:"triggered"`
+	FPS       float64     `json:"fps"`
+	Frames    []clipFrame `json:"frames"`
+}
+
+/**
+ * Rolling frame buffer of one camera. On a state change into one of
+ * the trigger states the buffer is kept as pre-roll, frames keep being
+ * collected for the post-roll and the clip is then written to disk.
+ */
+type Recorder struct {
+	Config RecordingConfig
+	Device CameraDevice
+
+	mu        sync.Mutex // Close is called when the stream stops
+	closed    bool
+	writes    sync.WaitGroup
+	buffer    []recordedFrame
+	clip      []recordedFrame // frames of the clip being collected
+	clipState SignState
+	triggered time.Time
+	until     time.Time
+	lastState SignState
+}
+
+func NewRecorder(config RecordingConfig, device CameraDevice) *Recorder {
+	return &Recorder{Config: config, Device: device}
+}
+
+/**
+ * Add a frame and start or finish clips on state changes.
+ * @param img image.Image unannotated frame, detections []Detection, state SignState, now time.Time
+ */
+func (r *Recorder) Update(img image.Image, detections []Detection, state SignState, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	// the frame of a new trigger is always kept, it starts the clip
+	trigger := state != r.lastState && r.triggers(state)
+	if r.Config.FPS > 0 && len(r.buffer) > 0 {
+		last := r.buffer[len(r.buffer)-1].Time
+		if now.Sub(last).Seconds() < 1/r.Config.FPS && !trigger {
+			return
+		}
+	}
+
+	data, err := encodeJPEG(img)
+	if err != nil {
+		fmt.Printf("Error buffering frame of %s: %v\n", r.Device.Name, err)
+		return
+	}
+	frame := recordedFrame{Time: now, JPEG: data, Detections: detections}
+
+	r.buffer = append(r.buffer, frame)
+	preRoll := time.Duration(r.Config.PreRoll * float64(time.Second))
+	for len(r.buffer) > 0 && now.Sub(r.buffer[0].Time) > preRoll {
+		r.buffer = r.buffer[1:]
+	}
+
+	postRoll := time.Duration(r.Config.PostRoll * float64(time.Second))
+	if trigger {
+		if r.clip == nil {
+			r.clip = append([]recordedFrame{}, r.buffer...)
+			r.clipState = state
+			r.triggered = now
+		} else {
+			r.clip = append(r.clip, frame)
+		}
+		// a new trigger during the post-roll extends the clip
+		r.until = now.Add(postRoll)
+	} else if r.clip != nil {
+		r.clip = append(r.clip, frame)
+	}
+	r.lastState = state
+
+	if r.clip != nil && now.After(r.until) {
+		r.finishClip()
+	}
+}
+
+/**
+ * Write the clip being collected, cut short if it is still in
+ * post-roll, and wait for the clips being written.
+ * Later frames are ignored.
+ */
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	r.closed = true
+	if r.clip != nil {
+		r.finishClip()
+	}
+	r.buffer = nil
+	r.mu.Unlock()
+
+	r.writes.Wait()
+}
+
+// called with mu held
+func (r *Recorder) finishClip() {
+	clip := r.clip
+	metadata := clipMetadata{
+		Camera:    r.Device.Name,
+		CameraID:  r.Device.ID,
+		State:     r.clipState.String(),
+		Triggered: r.triggered,
+	}
+	r.clip = nil
+
+	r.writes.Add(1)
+	go func() {
+		defer r.writes.Done()
+		r.write(clip, metadata)
+	}()
+}
+
+func (r *Recorder) triggers(state SignState) bool {
+	for _, name := range r.Config.TriggerStates {
+		if name == state.String() {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Write a clip and its sidecar, then enforce quota and retention.
+ * @param frames []recordedFrame, metadata clipMetadata
+ */
+func (r *Recorder) write(frames []recordedFrame, metadata clipMetadata) {
+	if len(frames) == 0 {
+		return
+	}
+	if err := os.MkdirAll(r.Config.Dir, 0755); err != nil {
+		fmt.Printf("Error creating recording dir: %v\n", err)
+		return
+	}
+
+	first, err := gocv.IMDecode(frames[0].JPEG, gocv.IMReadColor)
+	if err != nil {
+		fmt.Printf("Error decoding clip frame: %v\n", err)
+		return
+	}
+	width, height := first.Cols(), first.Rows()
+	first.Close()
+
+	// the processed frame rate varies, so use the rate of this clip
+	metadata.FPS = r.Config.FPS
+	if duration := frames[len(frames)-1].Time.Sub(frames[0].Time).Seconds(); len(frames) > 1 && duration > 0 {
+		metadata.FPS = float64(len(frames)-1) / duration
+	}
+
+	base := filepath.Join(r.Config.Dir, fmt.Sprintf("%s_%s_%s",
+		sanitizeFileName(r.Device.Name),
+		metadata.Triggered.Format("20060102_150405"),
+		sanitizeFileName(metadata.State)))
+
+	writer, err := gocv.VideoWriterFile(base+".avi", "MJPG", metadata.FPS, width, height, true)
+	if err != nil {
+		fmt.Printf("Error opening clip %s: %v\n", base, err)
+		return
+	}
+	defer writer.Close()
+
+	for _, frame := range frames {
+		mat, err := gocv.IMDecode(frame.JPEG, gocv.IMReadColor)
+		if err != nil {
+			continue
+		}
+		// metadata frames stay in step with the video frames for playback
+		if mat.Cols() == width && mat.Rows() == height {
+			writer.Write(mat)
+			metadata.Frames = append(metadata.Frames, clipFrame{Time: frame.Time, Detections: frame.Detections})
+		}
+		mat.Close()
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err == nil {
+		err = os.WriteFile(base+".json", data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Error writing clip metadata %s: %v\n", base, err)
+	}
+	fmt.Printf("Recorded %s (%d frames)\n", base+".avi", len(frames))
+
+	if err := cleanupRecordings(r.Config); err != nil {
+		fmt.Printf("Error cleaning up recordings: %v\n", err)
+	}
+}
+
+/**
+ * A recorded clip found on disk.
+ */
+type Clip struct {
+	Path     string // video file
+	Metadata clipMetadata
+}
+
+/**
+ * Time span covered by the clip.
+ * @return start, end time.Time
+ */
+func (c Clip) Span() (time.Time, time.Time) {
+	frames := c.Metadata.Frames
+	if len(frames) == 0 {
+		return c.Metadata.Triggered, c.Metadata.Triggered
+	}
+	return frames[0].Time, frames[len(frames)-1].Time
+}
+
+/**
+ * Read the sidecars of the recorded clips in a directory.
+ * @param dir string
+ * @return []Clip, error
+ */
+func loadClips(dir string) ([]Clip, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var clips []Clip
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var metadata clipMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			continue
+		}
+		video := strings.TrimSuffix(path, ".json") + ".avi"
+		if _, err := os.Stat(video); err != nil {
+			continue
+		}
+		clips = append(clips, Clip{Path: video, Metadata: metadata})
+	}
+	return clips, nil
+}
+
+/**
+ * Remove clips past the retention period, then the oldest
+ * clips until the directory fits the disk quota. A clip and
+ * its sidecar are always removed together.
+ * @param RecordingConfig
+ * @return error
+ */
+func cleanupRecordings(config RecordingConfig) error {
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return err
+	}
+
+	type recording struct {
+		base    string
+		paths   []string
+		size    int64
+		modTime time.Time // of the newest file
+	}
+	byBase := make(map[string]*recording)
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		base := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		rec := byBase[base]
+		if rec == nil {
+			rec = &recording{base: base}
+			byBase[base] = rec
+		}
+		rec.paths = append(rec.paths, filepath.Join(config.Dir, entry.Name()))
+		rec.size += info.Size()
+		if info.ModTime().After(rec.modTime) {
+			rec.modTime = info.ModTime()
+		}
+		total += info.Size()
+	}
+	recordings := make([]*recording, 0, len(byBase))
+	for _, rec := range byBase {
+		recordings = append(recordings, rec)
+	}
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].modTime.Before(recordings[j].modTime) })
+
+	cutoff := time.Now().AddDate(0, 0, -config.RetentionDays)
+	quota := config.MaxDiskMB * 1024 * 1024
+	for _, rec := range recordings {
+		expired := config.RetentionDays > 0 && rec.modTime.Before(cutoff)
+		overQuota := config.MaxDiskMB > 0 && total > quota
+		if !expired && !overQuota {
+			break
+		}
+		for _, path := range rec.paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		total -= rec.size
+		fmt.Printf("Removed recording %s\n", rec.base)
+	}
+	return nil
+}
+
+/**
+ * Encode a frame as jpeg.
+ * @param img image.Image
+ * @return []byte, error
+ */
+func encodeJPEG(img image.Image) ([]byte, error) {
+	mat, err := gocv.ImageToMatRGB(img)
+	if err != nil {
+		return nil, err
+	}
+	defer mat.Close()
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, mat)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Close()
+	return append([]byte{}, buf.GetBytes()...), nil
+}
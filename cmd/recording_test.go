@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupRecordingsRemovesPairs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	clips := []struct {
+		base string
+		age  time.Duration
+	}{
+		{"cam_old", 72 * time.Hour},
+		{"cam_mid", 2 * time.Hour},
+		{"cam_new", time.Minute},
+	}
+	for _, clip := range clips {
+		for _, ext := range []string{".avi", ".json"} {
+			path := filepath.Join(dir, clip.base+ext)
+			if err := os.WriteFile(path, make([]byte, 600*1024), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// the sidecar is written after the video
+			modTime := now.Add(-clip.age)
+			if ext == ".json" {
+				modTime = modTime.Add(time.Second)
+			}
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the old clip is expired, the middle one does not fit the quota
+	config := RecordingConfig{Dir: dir, MaxDiskMB: 2, RetentionDays: 2}
+	if err := cleanupRecordings(config); err != nil {
+		t.Fatalf("cleanupRecordings() error: %v", err)
+	}
+
+	for _, clip := range clips {
+		for _, ext := range []string{".avi", ".json"} {
+			_, err := os.Stat(filepath.Join(dir, clip.base+ext))
+			if kept := err == nil; kept != (clip.base == "cam_new") {
+				t.Errorf("%s%s kept = %v", clip.base, ext, kept)
+			}
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import "fmt"
+
+/**
+ * States of the sign display, ordered by priority.
+ * When several conditions are active the highest one wins.
+ */
+type SignState int
+
+const (
+	SignStateNormal SignState = iota
+	SignStateAnimal
+	SignStatePedestrian
+	SignStateStoppedVehicle
+	SignStateAccident
+	SignStateWrongWay
+)
+
+func (s SignState) String() string {
+	switch s {
+	case SignStateNormal:
+		return "normal"
+	case SignStateAnimal:
+		return "animal on road"
+	case SignStatePedestrian:
+		return "pedestrian on road"
+	case SignStateStoppedVehicle:
+		return "stopped vehicle"
+	case SignStateAccident:
+		return "accident"
+	case SignStateWrongWay:
+		return "wrong-way driver"
+	}
+	return "unknown"
+}
+
+// highest speed limit shown while a state is active, km/h
+var stateSpeedLimits = map[SignState]int{
+	SignStateAnimal:         50,
+	SignStatePedestrian:     30,
+	SignStateStoppedVehicle: 50,
+	SignStateAccident:       50,
+	SignStateWrongWay:       50,
+}
+
+/**
+ * Conditions found by the detection stages for one frame.
+ */
+type SignInputs struct {
+	Results    []Detection
+	Stopped    []*Track
+	WrongWay   bool
+	Pedestrian bool
+	Animal     bool
+}
+
+/**
+ * Pick the sign state from the current detections.
+ * A stopped vehicle is handled as a suspected accident.
+ * @param SignInputs
+ * @return SignState
+ */
+func evaluateSignState(inputs SignInputs) SignState {
+	if inputs.WrongWay {
+		return SignStateWrongWay
+	}
+	if searchAccident(inputs.Results) {
+		return SignStateAccident
+	}
+	if len(inputs.Stopped) > 0 {
+		return SignStateStoppedVehicle
+	}
+	if inputs.Pedestrian {
+		return SignStatePedestrian
+	}
+	if inputs.Animal {
+		return SignStateAnimal
+	}
+	return SignStateNormal
+}
+
+/**
+ * Combine the states of all cameras: the sign shows the highest
+ * state and the lowest speed limit of any camera, unless an
+ * operator override is active.
+ * @param *app
+ */
+func updateSignState(app *App) {
+	state := SignStateNormal
+	limit := app.Config.SpeedLimits.Default
+	stateCamera, limitCamera := -1, -1
+
+	for _, stream := range app.activeStreams() {
+		stream.mu.RLock()
+		if stream.SignState > state {
+			state = stream.SignState
+			stateCamera = stream.Device.ID
+		}
+		if stream.SpeedLimit < limit {
+			limit = stream.SpeedLimit
+			limitCamera = stream.Device.ID
+		}
+		stream.mu.RUnlock()
+	}
+
+	if override := app.activeOverride(); override != nil {
+		if override.State != "" {
+			state, stateCamera = override.state, -1
+		}
+		if override.SpeedLimit > 0 {
+			limit, limitCamera = override.SpeedLimit, -1
+		}
+	}
+
+	setSignState(app, state, stateCamera)
+	setSpeedLimit(app, limit, limitCamera)
+}
+
+/**
+ * Switch the sign to a new state, raising an event on transitions.
+ * @param *app, state SignState, cameraID of the camera causing it
+ */
+func setSignState(app *App, state SignState, cameraID int) {
+	app.SignMu.Lock()
+	previous := app.SignState
+	app.SignState = state
+	app.SignMu.Unlock()
+	if state == previous {
+		return
+	}
+
+	app.emitEvent(Event{
+		Type:     EventSignState,
+		CameraID: cameraID,
+		Message:  fmt.Sprintf("%s -> %s", previous, state),
+	})
+	app.UI.ShowSigns(app.currentSign())
+	app.publishSignState(cameraID)
+}
+
+/**
+ * Set the speed limit from the variable speed limit rules.
+ * @param *app, limit int km/h, cameraID of the camera causing it
+ */
+func setSpeedLimit(app *App, limit int, cameraID int) {
+	app.SignMu.Lock()
+	previous := app.SpeedLimit
+	app.SpeedLimit = limit
+	app.SignMu.Unlock()
+	if limit == previous {
+		return
+	}
+
+	app.emitEvent(Event{
+		Type:     EventSpeedLimit,
+		CameraID: cameraID,
+		Message:  fmt.Sprintf("%d -> %d km/h", previous, limit),
+	})
+	app.UI.ShowSigns(app.currentSign())
+	app.publishSignState(cameraID)
+}
+
+/**
+ * Push what the sign shows to the WebSocket clients, MQTT and metrics.
+ * @param *app, cameraID of the camera causing the change
+ */
+func (app *App) publishSignState(cameraID int) {
+	recordSignMetrics(app)
+	if app.MQTT != nil {
+		app.MQTT.PublishState()
+	}
+	if app.WSHub == nil {
+		return
+	}
+	app.WSHub.Broadcast(WSMessage{
+		Type:     WSMessageSignState,
+		CameraID: cameraID,
+		Data:     app.signStateMessage(),
+	}, true)
+}
+
+/**
+ * What the sign shows.
+ * @return SignState, int displayed speed limit km/h
+ */
+func (app *App) currentSign() (SignState, int) {
+	app.SignMu.Lock()
+	state, limit := app.SignState, app.SpeedLimit
+	app.SignMu.Unlock()
+	return state, displayedSpeedLimit(state, limit, app.activeOverride())
+}
+
+func (app *App) signStateMessage() signStateMessage {
+	state, limit := app.currentSign()
+	return signStateMessage{State: state.String(), SpeedLimit: limit}
+}
+
+/**
+ * Speed limit on the display, the rule based limit capped
+ * by the sign state.
+ * @param state SignState, limit int km/h, override *Override or nil
+ * @return int km/h
+ */
+func displayedSpeedLimit(state SignState, limit int, override *Override) int {
+	// a forced limit is shown as is
+	if override != nil && override.SpeedLimit > 0 {
+		return override.SpeedLimit
+	}
+	if capped, ok := stateSpeedLimits[state]; ok && capped < limit {
+		return capped
+	}
+	return limit
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	signDisplayTitle = "SmartSign™ Display"
+	// taps in the top left corner within exitGestureWindow close the sign display
+	exitGestureTaps   = 5
+	exitGestureWindow = 3 * time.Second
+	exitGestureCorner = 120
+)
+
+type SignDisplayConfig struct {
+	// open the sign window at startup
+	Enabled bool `json:"enabled"`
+	// xrandr output, e.g. "HDMI-1", empty for the primary monitor
+	Monitor string `json:"monitor"`
+	// close the window by tapping the top left corner instead of
+	// the window manager, so nothing on the sign closes it by accident
+	ExitGesture bool `json:"exitGesture"`
+	// turn off the screen saver and DPMS while the window is open
+	PreventBlanking bool `json:"preventBlanking"`
+}
+
+/**
+ * Fullscreen window for the physical display, showing only the
+ * sign composition without tabs or controls.
+ */
+type SignDisplay struct {
+	Window fyne.Window
+	Config SignDisplayConfig
+
+	app  *App
+	view *SignView
+}
+
+/**
+ * Open the sign window on the configured monitor.
+ * @param *app
+ */
+func openSignDisplay(app *App) {
+	if app.signDisplay() != nil {
+		return
+	}
+	config := app.Config.SignDisplay
+	display := &SignDisplay{
+		Window: fyne.CurrentApp().NewWindow(signDisplayTitle),
+		Config: config,
+		app:    app,
+		view:   NewSignView(app.currentSign()),
+	}
+
+	content := container.NewStack(canvas.NewRectangle(color.Black), display.view.Content)
+	if config.ExitGesture {
+		content.Add(newExitGesture(func() { closeSignDisplay(app) }))
+		display.Window.SetCloseIntercept(func() {})
+	} else {
+		// e.g. alt+F4 on a kiosk without the debug window
+		display.Window.SetCloseIntercept(func() { closeSignDisplay(app) })
+	}
+	display.Window.SetContent(content)
+	display.Window.SetPadded(false)
+
+	if config.PreventBlanking {
+		runX("xset", "s", "off", "s", "noblank", "-dpms")
+	}
+
+	if config.Monitor == "" {
+		display.Window.SetFullScreen(true)
+		display.Window.Show()
+	} else {
+		// fyne goes fullscreen on the monitor the window is on, move it there first
+		display.Window.Resize(fyne.NewSize(640, 360))
+		display.Window.Show()
+		go display.moveToMonitor(config.Monitor)
+	}
+	app.SignMu.Lock()
+	app.SignDisplay = display
+	app.SignMu.Unlock()
+}
+
+/**
+ * The open sign window.
+ * @return *SignDisplay, nil when the window is closed
+ */
+func (app *App) signDisplay() *SignDisplay {
+	app.SignMu.Lock()
+	defer app.SignMu.Unlock()
+	return app.SignDisplay
+}
+
+/**
+ * Close the sign window and allow the screen to blank again.
+ * @param *app
+ */
+func closeSignDisplay(app *App) {
+	if display := releaseSignDisplay(app); display != nil {
+		display.Window.Close()
+	}
+}
+
+/**
+ * Forget the sign window and allow the screen to blank again, at exit
+ * the window goes away with the app.
+ * @param *app
+ * @return *SignDisplay released, nil when none was open
+ */
+func releaseSignDisplay(app *App) *SignDisplay {
+	app.SignMu.Lock()
+	display := app.SignDisplay
+	app.SignDisplay = nil
+	app.SignMu.Unlock()
+	if display != nil && display.Config.PreventBlanking {
+		runX("xset", "s", "on", "+dpms")
+	}
+	return display
+}
+
+/**
+ * Show the sign composition for a state and limit.
+ * @param state SignState, speedLimit int km/h
+ */
+func (d *SignDisplay) Update(state SignState, speedLimit int) {
+	d.view.Update(state, speedLimit)
+}
+
+/**
+ * Speed limit roundel, warning triangle and text panel side by side,
+ * drawn by the sign renderer at the size they are shown.
+ */
+type SignView struct {
+	Content fyne.CanvasObject
+
+	mu    sync.Mutex
+	state SignState
+	limit int
+
+	speed   *canvas.Raster
+	warning *canvas.Raster
+	panel   *canvas.Raster
+}
+
+func NewSignView(state SignState, speedLimit int) *SignView {
+	view := &SignView{state: state, limit: speedLimit}
+	view.speed = canvas.NewRaster(func(w, h int) image.Image {
+		_, limit := view.current()
+		return RenderSpeedLimit(limit, w, h)
+	})
+	view.warning = canvas.NewRaster(func(w, h int) image.Image {
+		state, _ := view.current()
+		return RenderWarning(state, w, h)
+	})
+	view.panel = canvas.NewRaster(func(w, h int) image.Image {
+		state, _ := view.current()
+		return RenderTextPanel(signPanelTexts[state], w, h)
+	})
+	for _, raster := range []*canvas.Raster{view.speed, view.warning, view.panel} {
+		raster.SetMinSize(fyne.NewSize(100, 100))
+	}
+	view.Content = container.NewGridWithColumns(3, view.speed, view.warning, view.panel)
+	return view
+}
+
+func (v *SignView) current() (SignState, int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.state, v.limit
+}
+
+/**
+ * Redraw the parts that changed.
+ * @param state SignState, speedLimit int km/h
+ */
+func (v *SignView) Update(state SignState, speedLimit int) {
+	v.mu.Lock()
+	stateChanged, limitChanged := state != v.state, speedLimit != v.limit
+	v.state, v.limit = state, speedLimit
+	v.mu.Unlock()
+
+	if limitChanged {
+		v.speed.Refresh()
+	}
+	if stateChanged {
+		v.warning.Refresh()
+		v.panel.Refresh()
+	}
+}
+
+func (d *SignDisplay) moveToMonitor(name string) {
+	x, y, err := monitorOrigin(name)
+	if err != nil {
+		fmt.Printf("Error finding monitor, using the current one: %v\n", err)
+		d.Window.SetFullScreen(true)
+		return
+	}
+	// the window manager needs a moment to map the new window
+	geometry := fmt.Sprintf("0,%d,%d,-1,-1", x, y)
+	for i := 0; i < 20; i++ {
+		if err = exec.Command("wmctrl", "-r", signDisplayTitle, "-e", geometry).Run(); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		fmt.Printf("Error moving sign display to %s: %v\n", name, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	d.Window.SetFullScreen(true)
+}
+
+var xrandrOutput = regexp.MustCompile(`(?m)^(\S+) connected (?:primary )?\d+x\d+\+(\d+)\+(\d+)`)
+
+/**
+ * Position of a monitor in the X screen.
+ * @param name string xrandr output name
+ * @return x, y int, error
+ */
+func monitorOrigin(name string) (int, int, error) {
+	output, err := exec.Command("xrandr", "--query").Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to run xrandr: %v", err)
+	}
+	for _, match := range xrandrOutput.FindAllStringSubmatch(string(output), -1) {
+		if match[1] == name {
+			x, _ := strconv.Atoi(match[2])
+			y, _ := strconv.Atoi(match[3])
+			return x, y, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("monitor %s is not connected", name)
+}
+
+func runX(name string, args ...string) {
+	if output, err := exec.Command(name, args...).CombinedOutput(); err != nil {
+		fmt.Printf("Error running %s: %v %s\n", name, err, output)
+	}
+}
+
+/**
+ * Invisible layer over the sign that calls onExit after
+ * exitGestureTaps quick taps in the top left corner.
+ */
+type exitGesture struct {
+	widget.BaseWidget
+
+	onExit func()
+	taps   []time.Time
+}
+
+func newExitGesture(onExit func()) *exitGesture {
+	gesture := &exitGesture{onExit: onExit}
+	gesture.ExtendBaseWidget(gesture)
+	return gesture
+}
+
+func (g *exitGesture) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(canvas.NewRectangle(color.Transparent))
+}
+
+func (g *exitGesture) Tapped(event *fyne.PointEvent) {
+	if event.Position.X > exitGestureCorner || event.Position.Y > exitGestureCorner {
+		g.taps = nil
+		return
+	}
+
+	now := time.Now()
+	g.taps = append(g.taps, now)
+	for len(g.taps) > 0 && now.Sub(g.taps[0]) > exitGestureWindow {
+		g.taps = g.taps[1:]
+	}
+	if len(g.taps) >= exitGestureTaps {
+		g.taps = nil
+		g.onExit()
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+var (
+	signRed   = color.RGBA{200, 16, 46, 255}
+	signWhite = color.RGBA{255, 255, 255, 255}
+	signBlack = color.RGBA{0, 0, 0, 255}
+	// LED matrix look of the text panel
+	panelBackground = color.RGBA{16, 16, 16, 255}
+	panelText       = color.RGBA{255, 176, 0, 255}
+)
+
+/**
+ * Text panel lines shown next to the warning sign for each state.
+ */
+var signPanelTexts = map[SignState][]string{
+	SignStateAnimal:         {"ANIMALS", "ON ROAD"},
+	SignStatePedestrian:     {"PEOPLE", "ON ROAD"},
+	SignStateStoppedVehicle: {"STOPPED", "VEHICLE"},
+	SignStateAccident:       {"ACCIDENT", "AHEAD"},
+	SignStateWrongWay:       {"WRONG-WAY", "DRIVER"},
+}
+
+/**
+ * Speed limit roundel for any limit, centered in a w x h image.
+ * @param limit int km/h, nothing is drawn for 0, w, h int pixels
+ * @return *image.RGBA
+ */
+func RenderSpeedLimit(limit int, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if limit <= 0 {
+		return dst
+	}
+	p := newSignPainter(dst)
+	p.circle(signRed, 0.5, 0.5, 0.5)
+	p.circle(signWhite, 0.5, 0.5, 0.39)
+	p.text(signBlack, fmt.Sprint(limit), 0.5, 0.5, 0.4, 0.62)
+	return dst
+}
+
+/**
+ * Warning triangle with the pictogram of the state, centered in a
+ * w x h image. Nothing is drawn in the normal state.
+ * @param state SignState, w, h int pixels
+ * @return *image.RGBA
+ */
+func RenderWarning(state SignState, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if state == SignStateNormal {
+		return dst
+	}
+	p := newSignPainter(dst)
+
+	// equilateral triangle standing on the bottom of the unit square
+	top, left, right := [2]float32{0.5, 0.067}, [2]float32{0, 0.933}, [2]float32{1, 0.933}
+	p.polygon(signRed, top, left, right)
+	// inset around the centroid by the border width
+	centroid := [2]float32{0.5, 0.644}
+	const inset = 0.68
+	scale := func(v [2]float32) [2]float32 {
+		return [2]float32{centroid[0] + (v[0]-centroid[0])*inset, centroid[1] + (v[1]-centroid[1])*inset}
+	}
+	p.polygon(signWhite, scale(top), scale(left), scale(right))
+
+	pictogram := p.sub(0.5, 0.65, 0.42, 0)
+	switch state {
+	case SignStateAnimal:
+		drawDeer(pictogram)
+	case SignStatePedestrian:
+		drawPedestrian(pictogram)
+	case SignStateStoppedVehicle:
+		drawCar(pictogram.sub(0.5, 0.5, 0.95, 0), false)
+	case SignStateAccident:
+		drawCar(pictogram.sub(0.3, 0.6, 0.64, 0), false)
+		drawCar(pictogram.sub(0.72, 0.45, 0.64, 0.5), true)
+	case SignStateWrongWay:
+		drawArrow(pictogram.sub(0.3, 0.5, 1, 0))
+		drawArrow(pictogram.sub(0.7, 0.5, 1, math.Pi))
+	default:
+		drawExclamation(pictogram)
+	}
+	return dst
+}
+
+/**
+ * Text panel with centered lines, sized to fit a w x h image.
+ * @param lines []string, w, h int pixels
+ * @return *image.RGBA
+ */
+func RenderTextPanel(lines []string, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(panelBackground), image.Point{}, draw.Src)
+	if len(lines) == 0 {
+		return dst
+	}
+
+	p := &signPainter{dst: dst, z: vector.NewRasterizer(w, h), cx: float32(w) / 2, cy: float32(h) / 2, cos: 1}
+	lineHeight := float32(h) * 0.8 / float32(len(lines))
+	top := float32(h)*0.1 + lineHeight/2
+	for i, line := range lines {
+		p.textPixels(panelText, line, p.cx, top+float32(i)*lineHeight, lineHeight*0.7, float32(w)*0.9)
+	}
+	return dst
+}
+
+/**
+ * Draws shapes given in unit coordinates, [0,1] in both directions,
+ * into a square area of the image, optionally rotated.
+ */
+type signPainter struct {
+	dst *image.RGBA
+	z   *vector.Rasterizer
+	// center and side of the unit square in pixels
+	cx, cy, s float32
+	cos, sin  float32
+}
+
+func newSignPainter(dst *image.RGBA) *signPainter {
+	w, h := dst.Bounds().Dx(), dst.Bounds().Dy()
+	side := w
+	if h < side {
+		side = h
+	}
+	return &signPainter{
+		dst: dst,
+		z:   vector.NewRasterizer(w, h),
+		cx:  float32(w) / 2,
+		cy:  float32(h) / 2,
+		s:   float32(side),
+		cos: 1,
+	}
+}
+
+func (p *signPainter) point(u, v float32) (float32, float32) {
+	x, y := u-0.5, v-0.5
+	return p.cx + p.s*(x*p.cos-y*p.sin), p.cy + p.s*(x*p.sin+y*p.cos)
+}
+
+/**
+ * Painter for a square of the given size around (u, v), rotated by angle radians.
+ */
+func (p *signPainter) sub(u, v, size float32, angle float64) *signPainter {
+	cx, cy := p.point(u, v)
+	sin, cos := math.Sincos(angle)
+	return &signPainter{
+		dst: p.dst,
+		z:   p.z,
+		cx:  cx,
+		cy:  cy,
+		s:   p.s * size,
+		cos: p.cos*float32(cos) - p.sin*float32(sin),
+		sin: p.sin*float32(cos) + p.cos*float32(sin),
+	}
+}
+
+func (p *signPainter) fill(c color.Color) {
+	p.z.Draw(p.dst, p.dst.Bounds(), image.NewUniform(c), image.Point{})
+	p.z.Reset(p.dst.Bounds().Dx(), p.dst.Bounds().Dy())
+}
+
+func (p *signPainter) polygon(c color.Color, points ...[2]float32) {
+	for i, point := range points {
+		x, y := p.point(point[0], point[1])
+		if i == 0 {
+			p.z.MoveTo(x, y)
+		} else {
+			p.z.LineTo(x, y)
+		}
+	}
+	p.z.ClosePath()
+	p.fill(c)
+}
+
+func (p *signPainter) circle(c color.Color, u, v, r float32) {
+	cx, cy := p.point(u, v)
+	p.ellipse(cx, cy, r*p.s, r*p.s, false)
+	p.fill(c)
+}
+
+/**
+ * Add an axis aligned ellipse in pixels to the path. A reversed ellipse
+ * inside another one cuts a hole into it.
+ */
+func (p *signPainter) ellipse(cx, cy, rx, ry float32, reversed bool) {
+	// four cubic arcs, k places the control points
+	const k = 0.5523
+	if reversed {
+		ry = -ry
+	}
+	p.z.MoveTo(cx+rx, cy)
+	p.z.CubeTo(cx+rx, cy+k*ry, cx+k*rx, cy+ry, cx, cy+ry)
+	p.z.CubeTo(cx-k*rx, cy+ry, cx-rx, cy+k*ry, cx-rx, cy)
+	p.z.CubeTo(cx-rx, cy-k*ry, cx-k*rx, cy-ry, cx, cy-ry)
+	p.z.CubeTo(cx+k*rx, cy-ry, cx+rx, cy-k*ry, cx+rx, cy)
+	p.z.ClosePath()
+}
+
+/**
+ * Stroke through the points with round joins and caps.
+ */
+func (p *signPainter) line(c color.Color, width float32, points ...[2]float32) {
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		dx, dy := b[0]-a[0], b[1]-a[1]
+		length := float32(math.Hypot(float64(dx), float64(dy)))
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*width/2, dx/length*width/2
+		p.polygon(c,
+			[2]float32{a[0] + nx, a[1] + ny},
+			[2]float32{b[0] + nx, b[1] + ny},
+			[2]float32{b[0] - nx, b[1] - ny},
+			[2]float32{a[0] - nx, a[1] - ny},
+		)
+	}
+	for _, point := range points {
+		p.circle(c, point[0], point[1], width/2)
+	}
+}
+
+/**
+ * Text centered on (u, v), unrotated.
+ * @param height of the glyphs and maxWidth in unit coordinates
+ */
+func (p *signPainter) text(c color.Color, text string, u, v, height, maxWidth float32) {
+	x, y := p.point(u, v)
+	p.textPixels(c, text, x, y, height*p.s, maxWidth*p.s)
+}
+
+func (p *signPainter) textPixels(c color.Color, text string, x, y, height, maxWidth float32) {
+	face := signFace(float64(height))
+	if face == nil {
+		return
+	}
+	bounds, _ := font.BoundString(face, text)
+	if width := float32((bounds.Max.X - bounds.Min.X).Round()); width > maxWidth {
+		face.Close()
+		if face = signFace(float64(height * maxWidth / width)); face == nil {
+			return
+		}
+		bounds, _ = font.BoundString(face, text)
+	}
+	defer face.Close()
+
+	// center the ink, not the advance and line metrics
+	dot := fixed.Point26_6{
+		X: fixed.I(int(x)) - (bounds.Min.X+bounds.Max.X)/2,
+		Y: fixed.I(int(y)) - (bounds.Min.Y+bounds.Max.Y)/2,
+	}
+	drawer := font.Drawer{Dst: p.dst, Src: image.NewUniform(c), Face: face, Dot: dot}
+	for _, r := range text {
+		if r != '0' {
+			drawer.DrawString(string(r))
+			continue
+		}
+		// the Go font zero is slashed, signs use a plain oval the size of an eight
+		eight, advance := font.BoundString(face, "8")
+		minX, minY := fix32(drawer.Dot.X+eight.Min.X), fix32(drawer.Dot.Y+eight.Min.Y)
+		maxX, maxY := fix32(drawer.Dot.X+eight.Max.X), fix32(drawer.Dot.Y+eight.Max.Y)
+		rx, ry := (maxX-minX)/2, (maxY-minY)/2
+		stroke := rx * 0.5
+		p.ellipse(minX+rx, minY+ry, rx, ry, false)
+		p.ellipse(minX+rx, minY+ry, rx-stroke, ry-stroke*0.9, true)
+		p.fill(c)
+		drawer.Dot.X += advance
+	}
+}
+
+func fix32(v fixed.Int26_6) float32 {
+	return float32(v) / 64
+}
+
+var (
+	signFontOnce sync.Once
+	signFont     *opentype.Font
+)
+
+/**
+ * Bold face sized so digits and capitals are about size pixels high.
+ * @param size float64 pixels
+ * @return font.Face, nil when the font fails to load
+ */
+func signFace(size float64) font.Face {
+	signFontOnce.Do(func() {
+		parsed, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			fmt.Printf("Error loading sign font: %v\n", err)
+			return
+		}
+		signFont = parsed
+	})
+	if signFont == nil || size < 1 {
+		return nil
+	}
+	// cap height of Go Bold is about 0.73 em
+	face, err := opentype.NewFace(signFont, &opentype.FaceOptions{Size: size / 0.73, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		fmt.Printf("Error creating sign font face: %v\n", err)
+		return nil
+	}
+	return face
+}
+
+func drawExclamation(p *signPainter) {
+	p.polygon(signBlack, [2]float32{0.4, 0.02}, [2]float32{0.6, 0.02}, [2]float32{0.55, 0.7}, [2]float32{0.45, 0.7})
+	p.circle(signBlack, 0.5, 0.87, 0.1)
+}
+
+// side view facing right, or left when mirrored
+func drawCar(p *signPainter, mirrored bool) {
+	x := func(u float32) float32 {
+		if mirrored {
+			return 1 - u
+		}
+		return u
+	}
+	p.polygon(signBlack, [2]float32{x(0.22), 0.56}, [2]float32{x(0.33), 0.32}, [2]float32{x(0.66), 0.32}, [2]float32{x(0.8), 0.56})
+	p.polygon(signBlack, [2]float32{x(0.03), 0.54}, [2]float32{x(0.97), 0.54}, [2]float32{x(0.97), 0.76}, [2]float32{x(0.03), 0.76})
+	for _, u := range []float32{0.25, 0.75} {
+		p.circle(signBlack, x(u), 0.78, 0.11)
+		p.circle(signWhite, x(u), 0.78, 0.04)
+	}
+}
+
+// pointing up
+func drawArrow(p *signPainter) {
+	p.line(signBlack, 0.12, [2]float32{0.5, 0.3}, [2]float32{0.5, 0.95})
+	p.polygon(signBlack, [2]float32{0.5, 0.02}, [2]float32{0.72, 0.35}, [2]float32{0.28, 0.35})
+}
+
+// walking to the right
+func drawPedestrian(p *signPainter) {
+	p.circle(signBlack, 0.56, 0.1, 0.09)
+	p.line(signBlack, 0.14, [2]float32{0.54, 0.26}, [2]float32{0.48, 0.54})
+	p.line(signBlack, 0.08, [2]float32{0.53, 0.3}, [2]float32{0.66, 0.42}, [2]float32{0.74, 0.5})
+	p.line(signBlack, 0.08, [2]float32{0.52, 0.3}, [2]float32{0.38, 0.42}, [2]float32{0.32, 0.52})
+	p.line(signBlack, 0.1, [2]float32{0.48, 0.54}, [2]float32{0.62, 0.74}, [2]float32{0.68, 0.95})
+	p.line(signBlack, 0.1, [2]float32{0.48, 0.54}, [2]float32{0.4, 0.76}, [2]float32{0.26, 0.92})
+}
+
+// leaping deer facing right
+func drawDeer(p *signPainter) {
+	p.line(signBlack, 0.2, [2]float32{0.3, 0.52}, [2]float32{0.66, 0.46})
+	p.line(signBlack, 0.1, [2]float32{0.66, 0.44}, [2]float32{0.78, 0.26})
+	p.circle(signBlack, 0.81, 0.23, 0.07)
+	p.line(signBlack, 0.06, [2]float32{0.82, 0.23}, [2]float32{0.94, 0.28})
+	p.line(signBlack, 0.035, [2]float32{0.79, 0.18}, [2]float32{0.74, 0.04}, [2]float32{0.68, 0.02})
+	p.line(signBlack, 0.035, [2]float32{0.76, 0.1}, [2]float32{0.82, 0.05})
+	p.line(signBlack, 0.06, [2]float32{0.66, 0.5}, [2]float32{0.84, 0.6}, [2]float32{0.92, 0.74})
+	p.line(signBlack, 0.06, [2]float32{0.62, 0.52}, [2]float32{0.76, 0.66}, [2]float32{0.8, 0.8})
+	p.line(signBlack, 0.06, [2]float32{0.32, 0.56}, [2]float32{0.18, 0.72}, [2]float32{0.06, 0.86})
+	p.line(signBlack, 0.06, [2]float32{0.3, 0.54}, [2]float32{0.2, 0.66}, [2]float32{0.04, 0.7})
+	p.line(signBlack, 0.05, [2]float32{0.22, 0.46}, [2]float32{0.14, 0.4})
+}
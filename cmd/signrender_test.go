@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var signSizes = []image.Point{{1, 1}, {64, 64}, {200, 100}, {100, 300}, {640, 360}}
+
+/**
+ * Pixel at unit coordinates of the centered square, like signPainter.
+ */
+func signPixel(img *image.RGBA, u, v float64) color.RGBA {
+	size := img.Bounds().Size()
+	side := float64(shortSide(size))
+	x := float64(size.X)/2 + (u-0.5)*side
+	y := float64(size.Y)/2 + (v-0.5)*side
+	return img.RGBAAt(int(x), int(y))
+}
+
+func shortSide(size image.Point) int {
+	if size.X < size.Y {
+		return size.X
+	}
+	return size.Y
+}
+
+func isBlank(img *image.RGBA) bool {
+	for _, b := range img.Pix {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRenderSpeedLimit(t *testing.T) {
+	for _, limit := range []int{0, 30, 100, 120} {
+		for _, size := range signSizes {
+			t.Run(fmt.Sprintf("%d at %dx%d", limit, size.X, size.Y), func(t *testing.T) {
+				img := RenderSpeedLimit(limit, size.X, size.Y)
+				if img.Bounds().Size() != size {
+					t.Fatalf("size = %v, want %v", img.Bounds().Size(), size)
+				}
+				if limit == 0 {
+					if !isBlank(img) {
+						t.Errorf("limit 0 is not blank")
+					}
+					return
+				}
+				if shortSide(size) < 64 {
+					return
+				}
+				if c := signPixel(img, 0.95, 0.5); c != signRed {
+					t.Errorf("ring = %v, want red", c)
+				}
+				if c := signPixel(img, 0.5, 0.8); c != signWhite {
+					t.Errorf("centre below the number = %v, want white", c)
+				}
+				if c := signPixel(img, 0.02, 0.02); c.A != 0 {
+					t.Errorf("corner outside the roundel = %v, want transparent", c)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderWarning(t *testing.T) {
+	for state := SignStateNormal; state <= SignStateWrongWay; state++ {
+		for _, size := range signSizes {
+			t.Run(fmt.Sprintf("%s at %dx%d", state, size.X, size.Y), func(t *testing.T) {
+				img := RenderWarning(state, size.X, size.Y)
+				if img.Bounds().Size() != size {
+					t.Fatalf("size = %v, want %v", img.Bounds().Size(), size)
+				}
+				if state == SignStateNormal {
+					if !isBlank(img) {
+						t.Errorf("normal state is not blank")
+					}
+					return
+				}
+				if shortSide(size) < 64 {
+					return
+				}
+				if c := signPixel(img, 0.5, 0.89); c != signRed {
+					t.Errorf("border = %v, want red", c)
+				}
+				if c := signPixel(img, 0.22, 0.82); c != signWhite {
+					t.Errorf("inside the border = %v, want white", c)
+				}
+				if c := signPixel(img, 0.05, 0.1); c.A != 0 {
+					t.Errorf("corner outside the triangle = %v, want transparent", c)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderTextPanel(t *testing.T) {
+	for state := SignStateNormal; state <= SignStateWrongWay; state++ {
+		for _, size := range signSizes {
+			t.Run(fmt.Sprintf("%s at %dx%d", state, size.X, size.Y), func(t *testing.T) {
+				img := RenderTextPanel(signPanelTexts[state], size.X, size.Y)
+				if img.Bounds().Size() != size {
+					t.Fatalf("size = %v, want %v", img.Bounds().Size(), size)
+				}
+				if c := img.RGBAAt(0, 0); c != panelBackground {
+					t.Errorf("background = %v, want %v", c, panelBackground)
+				}
+
+				lit := false
+				for y := 0; y < size.Y && !lit; y++ {
+					for x := 0; x < size.X && !lit; x++ {
+						lit = img.RGBAAt(x, y) != panelBackground
+					}
+				}
+				if want := state != SignStateNormal && shortSide(size) >= 64; lit != want {
+					t.Errorf("text drawn = %v, want %v", lit, want)
+				}
+			})
+		}
+	}
+}
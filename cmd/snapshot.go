@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+type SnapshotConfig struct {
+	Dir string `json:"dir"`
+	// take a snapshot whenever the sign state of a camera changes
+	OnStateChange bool `json:"onStateChange"`
+}
+
+/**
+ * Metadata of a snapshot, stored as json next to the images.
+ */
+type Snapshot struct {
+	Camera        string      `json:"camera"`
+	CameraID      int         `json:"cameraId"`
+	Time          time.Time   `json:"time"`
+	Reason        string      `json:"reason"`
+	State         string      `json:"state"`
+	Detections    []Detection `json:"detections"`
+	RawPath       string      `json:"raw"`
+	AnnotatedPath string      `json:"annotated"`
+}
+
+/**
+ * Save the latest raw and annotated frames of a camera.
+ * @param reason string shown in the gallery
+ * @return Snapshot, error
+ */
+func (s *CameraStream) takeSnapshot(reason string) (Snapshot, error) {
+	raw, okRaw := s.RawImage.Load().(image.Image)
+	annotated, okAnnotated := s.CurrentImage.Load().(image.Image)
+	if !okRaw || !okAnnotated {
+		return Snapshot{}, fmt.Errorf("no frame from %s yet", s.Device.Name)
+	}
+
+	config := s.app.Config.Snapshots
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create snapshot dir: %v", err)
+	}
+
+	now := time.Now()
+	base := filepath.Join(config.Dir, fmt.Sprintf("%s_%s",
+		sanitizeFileName(s.Device.Name), now.Format("20060102_150405.000")))
+	s.mu.RLock()
+	snapshot := Snapshot{
+		Camera:        s.Device.Name,
+		CameraID:      s.Device.ID,
+		Time:          now,
+		Reason:        reason,
+		State:         s.SignState.String(),
+		Detections:    s.Detections,
+		RawPath:       base + "_raw.jpg",
+		AnnotatedPath: base + "_annotated.jpg",
+	}
+	s.mu.RUnlock()
+
+	if err := writeJPEG(snapshot.RawPath, raw); err != nil {
+		return Snapshot{}, err
+	}
+	if err := writeJPEG(snapshot.AnnotatedPath, annotated); err != nil {
+		return Snapshot{}, err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to write snapshot %s: %v", base, err)
+	}
+
+	s.app.UI.SnapshotsChanged()
+	return snapshot, nil
+}
+
+func writeJPEG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	if err := jpeg.Encode(file, img, &jpeg.Options{Quality: 90}); err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	return nil
+}
+
+/**
+ * Read the snapshot metadata in a directory, newest first.
+ * @param dir string
+ * @return []Snapshot, error
+ */
+func loadSnapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.After(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+/**
+ * Gallery tab listing the saved snapshots with the raw and
+ * annotated frames and detections of the selected one.
+ */
+type Gallery struct {
+	Content fyne.CanvasObject
+
+	app       *App
+	snapshots []Snapshot
+	list      *widget.List
+	raw       *canvas.Image
+	annotated *canvas.Image
+	details   *widget.Label
+}
+
+func NewGallery(app *App) *Gallery {
+	g := &Gallery{app: app}
+
+	g.raw = canvas.NewImageFromImage(nil)
+	g.raw.FillMode = canvas.ImageFillContain
+	g.annotated = canvas.NewImageFromImage(nil)
+	g.annotated.FillMode = canvas.ImageFillContain
+	g.details = widget.NewLabel("Select a snapshot")
+
+	g.list = widget.NewList(
+		func() int { return len(g.snapshots) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			snapshot := g.snapshots[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("%s %s (%s)",
+				snapshot.Time.Format(time.DateTime), snapshot.Camera, snapshot.Reason))
+		},
+	)
+	g.list.OnSelected = g.show
+
+	reloadBtn := widget.NewButton("Reload", g.Reload)
+
+	images := container.NewGridWithColumns(2, g.annotated, g.raw)
+	preview := container.NewBorder(nil, g.details, nil, nil, images)
+	split := container.NewHSplit(container.NewBorder(nil, reloadBtn, nil, nil, g.list), preview)
+	split.Offset = 0.25
+	g.Content = split
+
+	g.Reload()
+	return g
+}
+
+/**
+ * Re-read the snapshot directory.
+ */
+func (g *Gallery) Reload() {
+	snapshots, err := loadSnapshots(g.app.Config.Snapshots.Dir)
+	if err != nil {
+		g.details.SetText(err.Error())
+		return
+	}
+	g.snapshots = snapshots
+	g.list.UnselectAll()
+	g.list.Refresh()
+}
+
+func (g *Gallery) show(id widget.ListItemID) {
+	if id >= len(g.snapshots) {
+		return
+	}
+	snapshot := g.snapshots[id]
+
+	g.raw.File = snapshot.RawPath
+	g.raw.Refresh()
+	g.annotated.File = snapshot.AnnotatedPath
+	g.annotated.Refresh()
+
+	var details strings.Builder
+	details.WriteString(fmt.Sprintf("%s, camera %s, state %s, %s\n",
+		snapshot.Time.Format(time.DateTime), snapshot.Camera, snapshot.State, snapshot.Reason))
+	for _, detection := range snapshot.Detections {
+		details.WriteString(fmt.Sprintf("%s %.2f [%.0f, %.0f, %.0f, %.0f]\n",
+			detection.ClassName, detection.Confidence,
+			detection.BBox.XMin, detection.BBox.YMin, detection.BBox.XMax, detection.BBox.YMax))
+	}
+	g.details.SetText(details.String())
+}
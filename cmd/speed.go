@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// track history used for a single speed measurement
+	speedWindow = 1 * time.Second
+	// lane averages are taken over this period
+	laneSpeedWindow = 1 * time.Minute
+)
+
+type SpeedLimitConfig struct {
+	// limit shown when no rule matches, km/h
+	Default int         `json:"default"`
+	Rules   []SpeedRule `json:"rules"`
+	// km/h the averages have to rise above BelowAverage to release a rule
+	Hysteresis float64 `json:"hysteresisKmh"`
+	// seconds a limit is kept at least before it is raised again
+	MinHold float64 `json:"minHoldSeconds"`
+}
+
+/**
+ * Lower the limit when the average speed of any lane drops
+ * below BelowAverage, e.g. to protect the end of a queue.
+ */
+type SpeedRule struct {
+	BelowAverage float64 `json:"belowAverage"`
+	Limit        int     `json:"limit"`
+}
+
+/**
+ * Estimates track speeds on the calibrated ground plane
+ * and keeps a moving average per lane.
+ */
+type SpeedEstimator struct {
+	samples map[string][]speedSample
+}
+
+type speedSample struct {
+	time  time.Time
+	track int
+	speed float64
+}
+
+func NewSpeedEstimator() *SpeedEstimator {
+	return &SpeedEstimator{samples: make(map[string][]speedSample)}
+}
+
+/**
+ * Set Track.Speed in km/h from the movement of the box bottom center
+ * over the last speedWindow and record it for the lane averages.
+ * @param tracks []*Track, h Homography, now time.Time
+ */
+func (s *SpeedEstimator) Update(tracks []*Track, h Homography, now time.Time) {
+	for _, track := range tracks {
+		track.Speed = estimateSpeed(track.History, h, now)
+		if track.Speed <= 0 {
+			continue
+		}
+		s.samples[track.Lane] = append(s.samples[track.Lane], speedSample{time: now, track: track.ID, speed: track.Speed})
+	}
+
+	oldest := now.Add(-laneSpeedWindow)
+	for lane, samples := range s.samples {
+		i := 0
+		for i < len(samples) && samples[i].time.Before(oldest) {
+			i++
+		}
+		if i == len(samples) {
+			delete(s.samples, lane)
+			continue
+		}
+		s.samples[lane] = samples[i:]
+	}
+}
+
+/**
+ * Average speed per lane over laneSpeedWindow. Every vehicle is
+ * weighted the same, no matter how long it was in view.
+ * @return map[string]float64 km/h by lane name, "" for no lane
+ */
+func (s *SpeedEstimator) LaneAverages() map[string]float64 {
+	averages := make(map[string]float64)
+	for lane, samples := range s.samples {
+		perTrack := make(map[int][]float64)
+		for _, sample := range samples {
+			perTrack[sample.track] = append(perTrack[sample.track], sample.speed)
+		}
+
+		var sum float64
+		for _, speeds := range perTrack {
+			sum += mean(speeds)
+		}
+		averages[lane] = sum / float64(len(perTrack))
+	}
+	return averages
+}
+
+func estimateSpeed(history []TrackPoint, h Homography, now time.Time) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	last := history[len(history)-1]
+	first := last
+	for i := len(history) - 2; i >= 0; i-- {
+		if last.Time.Sub(history[i].Time) > speedWindow {
+			break
+		}
+		first = history[i]
+	}
+
+	dt := last.Time.Sub(first.Time).Seconds()
+	if dt < speedWindow.Seconds()/2 {
+		return 0
+	}
+
+	x0, y0 := h.Apply(normalizePoint(first.BBox.Anchor()))
+	x1, y1 := h.Apply(normalizePoint(last.BBox.Anchor()))
+	meters := math.Hypot(x1-x0, y1-y0)
+
+	return meters / dt * 3.6
+}
+
+/**
+ * Speed limit from the variable speed limit rules. A rule that holds
+ * the current limit down stays engaged until the averages are
+ * Hysteresis km/h above its threshold.
+ * @param config SpeedLimitConfig, averages map[string]float64, current int km/h
+ * @return int km/h
+ */
+func evaluateSpeedLimit(config SpeedLimitConfig, averages map[string]float64, current int) int {
+	limit := config.Default
+	for _, rule := range config.Rules {
+		threshold := rule.BelowAverage
+		if current <= rule.Limit {
+			threshold += config.Hysteresis
+		}
+		for _, average := range averages {
+			if average < threshold && rule.Limit < limit {
+				limit = rule.Limit
+			}
+		}
+	}
+	return limit
+}
+
+/**
+ * Variable speed limit of one camera. Lower limits apply at once,
+ * a higher limit only once the current one has been shown for
+ * MinHold seconds, so the sign does not flicker in dense traffic.
+ */
+type SpeedLimiter struct {
+	Config SpeedLimitConfig
+
+	limit   int
+	changed time.Time
+}
+
+func NewSpeedLimiter(config SpeedLimitConfig) *SpeedLimiter {
+	return &SpeedLimiter{Config: config, limit: config.Default}
+}
+
+/**
+ * Evaluate the rules against the latest lane averages.
+ * @param averages map[string]float64, now time.Time
+ * @return int km/h
+ */
+func (l *SpeedLimiter) Update(averages map[string]float64, now time.Time) int {
+	limit := evaluateSpeedLimit(l.Config, averages, l.limit)
+	hold := time.Duration(l.Config.MinHold * float64(time.Second))
+	if limit > l.limit && now.Sub(l.changed) < hold {
+		return l.limit
+	}
+	if limit != l.limit {
+		l.limit = limit
+		l.changed = now
+	}
+	return l.limit
+}
+
+func sortedLanes(averages map[string]float64) []string {
+	lanes := make([]string, 0, len(averages))
+	for lane := range averages {
+		lanes = append(lanes, lane)
+	}
+	sort.Strings(lanes)
+	return lanes
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func testSpeedLimitConfig() SpeedLimitConfig {
+	return SpeedLimitConfig{
+		Default: 100,
+		Rules: []SpeedRule{
+			{BelowAverage: 40, Limit: 50},
+			{BelowAverage: 20, Limit: 30},
+		},
+		Hysteresis: 10,
+		MinHold:    60,
+	}
+}
+
+func TestEvaluateSpeedLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		averages map[string]float64
+		current  int
+		want     int
+	}{
+		{"no traffic", nil, 100, 100},
+		{"free flow", map[string]float64{"1": 90, "2": 80}, 100, 100},
+		{"one slow lane", map[string]float64{"1": 90, "2": 35}, 100, 50},
+		{"queue", map[string]float64{"1": 15, "2": 35}, 100, 30},
+		{"inside the hysteresis band", map[string]float64{"1": 45}, 50, 50},
+		{"above the hysteresis band", map[string]float64{"1": 55}, 50, 100},
+		{"band of the lower rule", map[string]float64{"1": 25}, 30, 30},
+		{"band does not engage a rule", map[string]float64{"1": 45}, 100, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := evaluateSpeedLimit(testSpeedLimitConfig(), test.averages, test.current); got != test.want {
+				t.Errorf("evaluateSpeedLimit() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSpeedLimiterHoldsLimit(t *testing.T) {
+	limiter := NewSpeedLimiter(testSpeedLimitConfig())
+	start := time.Now()
+	slow := map[string]float64{"1": 30}
+	fast := map[string]float64{"1": 90}
+
+	if got := limiter.Update(slow, start); got != 50 {
+		t.Fatalf("limit = %d, want 50 right away", got)
+	}
+	if got := limiter.Update(fast, start.Add(30*time.Second)); got != 50 {
+		t.Errorf("limit = %d, want 50 within the hold time", got)
+	}
+	if got := limiter.Update(map[string]float64{"1": 10}, start.Add(40*time.Second)); got != 30 {
+		t.Errorf("limit = %d, want 30, lowering must not wait", got)
+	}
+	if got := limiter.Update(fast, start.Add(90*time.Second)); got != 30 {
+		t.Errorf("limit = %d, want 30, the hold restarts on every change", got)
+	}
+	if got := limiter.Update(fast, start.Add(101*time.Second)); got != 100 {
+		t.Errorf("limit = %d, want 100 after the hold time", got)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+/**
+ * Finds vehicle tracks that stand still inside the camera's include
+ * or lane zones. A vehicle stopped on the carriageway is handled by
+ * the sign logic like a suspected accident. Without road zones the
+ * detector does nothing, a parked car could not be told apart.
+ */
+type StoppedVehicleDetector struct {
+	Config         StoppedVehicleConfig
+	VehicleClasses []string
+
+	stationary map[int]stationaryState
+}
+
+type stationaryState struct {
+	ref      Point // anchor where the track stopped moving
+	since    time.Time
+	reported bool
+}
+
+func NewStoppedVehicleDetector(config StoppedVehicleConfig, vehicleClasses []string) *StoppedVehicleDetector {
+	return &StoppedVehicleDetector{
+		Config:         config,
+		VehicleClasses: vehicleClasses,
+		stationary:     make(map[int]stationaryState),
+	}
+}
+
+/**
+ * Update stationary timers and return the tracks that have
+ * been stopped for longer than the configured duration.
+ * Newly stopped vehicles raise an EventStoppedVehicle.
+ * @param *stream, tracks []*Track, zones []Zone of the camera, now time.Time
+ * @return []*Track
+ */
+func (s *StoppedVehicleDetector) Update(stream *CameraStream, tracks []*Track, zones []Zone, now time.Time) []*Track {
+	var stopped []*Track
+	seen := make(map[int]bool)
+	if !hasRoadZone(zones) {
+		tracks = nil
+	}
+
+	for _, track := range tracks {
+		if !containsString(s.VehicleClasses, track.ClassName) || !onRoad(track, zones) {
+			continue
+		}
+
+		anchor := normalizePoint(track.BBox.Anchor())
+		seen[track.ID] = true
+
+		state, ok := s.stationary[track.ID]
+		if !ok || distance(anchor, state.ref) > s.Config.MaxMovement {
+			s.stationary[track.ID] = stationaryState{ref: anchor, since: now}
+			continue
+		}
+
+		duration := now.Sub(state.since)
+		if duration.Seconds() < s.Config.MinDuration {
+			continue
+		}
+		stopped = append(stopped, track)
+
+		if !state.reported {
+			state.reported = true
+			s.stationary[track.ID] = state
+			stream.queueEvent(Event{
+				Time:    now,
+				Type:    EventStoppedVehicle,
+				TrackID: track.ID,
+				Message: fmt.Sprintf("vehicle #%d stopped for %.0fs", track.ID, duration.Seconds()),
+			})
+		}
+	}
+
+	// forget tracks that were lost
+	for id := range s.stationary {
+		if !seen[id] {
+			delete(s.stationary, id)
+		}
+	}
+
+	return stopped
+}
+
+func distance(a, b Point) float32 {
+	dx := float64(a.X - b.X)
+	dy := float64(a.Y - b.Y)
+	return float32(math.Sqrt(dx*dx + dy*dy))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var testRoadZones = []Zone{
+	{Name: "road", Kind: ZoneInclude, Polygon: []Point{{0, 0.5}, {1, 0.5}, {1, 1}, {0, 1}}},
+	{Name: "parking", Kind: ZoneExclude, Polygon: []Point{{0, 0}, {0.2, 0}, {0.2, 0.2}, {0, 0.2}}},
+}
+
+func TestStoppedVehicleDetector(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   string
+		zones   []string
+		cameras []Zone
+		want    bool
+	}{
+		{"car on the road", "car", []string{"road"}, testRoadZones, true},
+		{"truck on the road", "truck", []string{"road"}, testRoadZones, true},
+		{"person on the road", "person", []string{"road"}, testRoadZones, false},
+		{"car outside the road zones", "car", nil, testRoadZones, false},
+		{"no road zone configured", "car", nil, nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			detector := NewStoppedVehicleDetector(StoppedVehicleConfig{MinDuration: 10, MaxMovement: 0.02}, []string{"car", "truck"})
+			stream := &CameraStream{}
+			track := &Track{ID: 1, ClassName: test.class, Zones: test.zones, BBox: BoundingBox{XMin: 300, YMin: 400, XMax: 340, YMax: 440}}
+			now := time.Now()
+
+			detector.Update(stream, []*Track{track}, test.cameras, now)
+			stopped := detector.Update(stream, []*Track{track}, test.cameras, now.Add(11*time.Second))
+
+			if got := len(stopped) == 1; got != test.want {
+				t.Errorf("stopped = %v, want %v", got, test.want)
+			}
+			if got := len(stream.pendingEvents) == 1; got != test.want {
+				t.Errorf("got %d events, want one only when stopped", len(stream.pendingEvents))
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// consecutive failed reads before a camera fault is reported, ~1s
+const maxReadFailures = 30
+
+/**
+ * A camera that is streamed and run through detection.
+ * Capture runs in its own goroutine and only stores the latest frame,
+ * the detection loop picks frames up from all streams, so a slow model
+ * never blocks capture. Detection state is only touched by the
+ * detection loop.
+ */
+type CameraStream struct {
+	Device CameraDevice
+
+	// guards the detection state for readers outside the detection loop
+	mu       sync.RWMutex
+	app      *App
+	stop     chan bool
+	frame    atomic.Value // latest captured image.Image
+	frameSeq atomic.Uint64
+	// last frame sequence run through detection
+	processedSeq atomic.Uint64
+
+	// latest annotated frame and the raw frame it was drawn on
+	CurrentImage *atomic.Value
+	RawImage     *atomic.Value
+	FPS          float64 // processed frames per second, smoothed
+	// jpeg encoded frames for the MJPEG streams
+	annotatedJPEG jpegCache
+	rawJPEG       jpegCache
+	lastImage     time.Time
+	Tile          *CameraTile
+
+	// Detection
+	Detections      []Detection
+	Tracker         *Tracker
+	Tracks          []*Track
+	StoppedVehicles *StoppedVehicleDetector
+	StoppedTracks   []*Track
+	WrongWay        *WrongWayDetector
+	WrongWayTracks  []*Track
+	Hazards         *HazardDetector
+	HazardTracks    []*Track
+	Counter         *LineCounter
+	SpeedEstimator  *SpeedEstimator
+	SpeedLimiter    *SpeedLimiter
+	InferenceTime   time.Duration
+	Recorder        *Recorder // nil when recording is disabled
+	pendingEvents   []Event   // raised under mu, emitted after unlocking
+
+	// Sign logic, the sign shows the highest state of all cameras
+	SignState  SignState
+	SpeedLimit int
+}
+
+func NewCameraStream(app *App, device CameraDevice) *CameraStream {
+	stream := &CameraStream{
+		Device:          device,
+		app:             app,
+		stop:            make(chan bool),
+		CurrentImage:    &atomic.Value{},
+		RawImage:        &atomic.Value{},
+		Tracker:         NewTracker(),
+		StoppedVehicles: NewStoppedVehicleDetector(app.Config.StoppedVehicle, app.Config.VehicleClasses),
+		WrongWay:        NewWrongWayDetector(app.Config.WrongWay, app.Config.VehicleClasses),
+		Hazards:         NewHazardDetector(app.Config.Hazards),
+		Counter:         NewLineCounter(app.Config.VehicleClasses),
+		SpeedEstimator:  NewSpeedEstimator(),
+		SpeedLimiter:    NewSpeedLimiter(app.Config.SpeedLimits),
+		SpeedLimit:      app.Config.SpeedLimits.Default,
+	}
+	if app.Config.Recording.Enabled {
+		stream.Recorder = NewRecorder(app.Config.Recording, device)
+	}
+	return stream
+}
+
+/**
+ * Read frames until the stream is stopped.
+ * @param cam *gocv.VideoCapture, closed when the loop ends
+ */
+func (s *CameraStream) capture(cam *gocv.VideoCapture) {
+	defer s.app.Workers.Done()
+	defer cam.Close()
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	camera := cameraLabel(s.Device)
+	metricCameraInfo.WithLabelValues(camera, s.Device.Name, s.Device.Path).Set(1)
+	metricCameraUp.WithLabelValues(camera).Set(1)
+	defer metricCameraUp.WithLabelValues(camera).Set(0)
+
+	failures := 0
+	var fps float64
+	var lastRead time.Time
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+			if ok := cam.Read(&frame); !ok || frame.Empty() {
+				failures++
+				metricReadFailures.WithLabelValues(camera).Inc()
+				if failures == maxReadFailures {
+					metricCameraUp.WithLabelValues(camera).Set(0)
+					s.emitEvent(Event{Type: EventCameraFault, Message: fmt.Sprintf("no frames from %s", s.Device.Path)})
+				}
+			} else {
+				if failures >= maxReadFailures {
+					metricCameraUp.WithLabelValues(camera).Set(1)
+					s.emitEvent(Event{Type: EventCameraFault, Message: fmt.Sprintf("%s recovered", s.Device.Path)})
+				}
+				failures = 0
+
+				now := time.Now()
+				if !lastRead.IsZero() {
+					fps = 0.9*fps + 0.1/now.Sub(lastRead).Seconds()
+					metricCaptureFPS.WithLabelValues(camera).Set(fps)
+				}
+				lastRead = now
+				metricFramesCaptured.WithLabelValues(camera).Inc()
+
+				img, _ := frame.ToImage()
+				// the previous frame was never picked up by detection
+				if s.frameSeq.Load() != s.processedSeq.Load() && s.app.modelCount() > 0 {
+					metricFramesDropped.WithLabelValues(camera).Inc()
+				}
+				s.frame.Store(img)
+				s.frameSeq.Add(1)
+
+				// without models the raw feed is shown as is
+				if s.app.modelCount() == 0 {
+					masked, err := maskPrivacy(img, s.app.Config.Privacy, nil, s.app.cameraPrivacyMasks(s.Device))
+					if err != nil {
+						fmt.Printf("Dropping frame of %s: %v\n", s.Device.Name, err)
+					} else {
+						s.RawImage.Store(masked)
+						s.setImage(masked)
+					}
+				}
+			}
+			time.Sleep(33 * time.Millisecond) // ~30 FPS
+		}
+	}
+}
+
+/**
+ * Latest frame if it has not been run through detection yet.
+ * @return image.Image, bool
+ */
+func (s *CameraStream) nextFrame() (image.Image, bool) {
+	seq := s.frameSeq.Load()
+	if seq == s.processedSeq.Load() {
+		return nil, false
+	}
+	img, ok := s.frame.Load().(image.Image)
+	if !ok {
+		return nil, false
+	}
+	s.processedSeq.Store(seq)
+	return img, true
+}
+
+/**
+ * Store the annotated frame and show it when the camera is focused.
+ * @param img image.Image
+ */
+func (s *CameraStream) setImage(img image.Image) {
+	now := time.Now()
+	s.mu.Lock()
+	if !s.lastImage.IsZero() {
+		if elapsed := now.Sub(s.lastImage).Seconds(); elapsed > 0 {
+			s.FPS = 0.9*s.FPS + 0.1/elapsed
+		}
+	}
+	s.lastImage = now
+	s.mu.Unlock()
+
+	s.CurrentImage.Store(img)
+	if s.app.activeCamera() == s.Device.ID {
+		s.app.CurrentImage.Store(img)
+	}
+	s.app.UI.ShowFrame(s)
+}
+
+/**
+ * Grid tile showing this stream, set from the UI goroutine.
+ * @return *CameraTile, nil when the grid is not shown
+ */
+func (s *CameraStream) tile() *CameraTile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Tile
+}
+
+func (s *CameraStream) setTile(tile *CameraTile) {
+	s.mu.Lock()
+	s.Tile = tile
+	s.mu.Unlock()
+}
+
+/**
+ * Record an event of this camera.
+ * @param Event
+ */
+func (s *CameraStream) emitEvent(event Event) {
+	event.CameraID = s.Device.ID
+	s.app.emitEvent(event)
+}
+
+/**
+ * Queue an event raised while mu is held, processDetections
+ * emits it once the lock is released.
+ * @param Event
+ */
+func (s *CameraStream) queueEvent(event Event) {
+	s.pendingEvents = append(s.pendingEvents, event)
+}
+
+/**
+ * Run the per camera stages on the detections of a frame: zone
+ * filtering, tracking, incident detection, counting and speeds.
+ * The frame is privacy masked before it is stored or drawn on.
+ * @param img image.Image, results []Detection, now time.Time
+ * @return image.Image annotated frame, error when the frame could not be masked
+ */
+func (s *CameraStream) processDetections(img image.Image, results []Detection, now time.Time) (image.Image, error) {
+	s.mu.Lock()
+
+	zones := s.app.cameraZones(s.Device)
+
+	results, private := splitPrivacyDetections(s.app.Config.Privacy, results)
+	results = filterDetectionsByZone(results, zones)
+	s.Detections = results
+	s.Tracks = s.Tracker.Update(results, now)
+	s.StoppedTracks = s.StoppedVehicles.Update(s, s.Tracker.Tracks(), zones, now)
+	s.WrongWayTracks = s.WrongWay.Update(s, s.Tracks, zones, now)
+	s.HazardTracks = s.Hazards.Update(s, s.Tracker.Tracks(), zones, now)
+
+	lines := s.app.cameraLines(s.Device)
+	s.Counter.Update(lines, s.Tracks, now)
+
+	if h, ok := s.app.cameraHomography(s.Device); ok {
+		s.SpeedEstimator.Update(s.Tracks, h, now)
+	}
+
+	s.SignState = evaluateSignState(SignInputs{
+		Results:    results,
+		Stopped:    s.StoppedTracks,
+		WrongWay:   s.WrongWay.Active(now),
+		Pedestrian: s.Hazards.Active(HazardPedestrian, now),
+		Animal:     s.Hazards.Active(HazardAnimal, now),
+	})
+	s.SpeedLimit = s.SpeedLimiter.Update(s.SpeedEstimator.LaneAverages(), now)
+
+	tracks, state := s.Tracks, s.SignState
+	events := s.pendingEvents
+	s.pendingEvents = nil
+	s.mu.Unlock()
+
+	// storing and publishing is slow, API readers must not wait for it
+	for _, event := range events {
+		s.emitEvent(event)
+	}
+
+	// everything below leaves the device, so it gets the masked frame
+	img, err := maskPrivacy(img, s.app.Config.Privacy, private, s.app.cameraPrivacyMasks(s.Device))
+	if err != nil {
+		return nil, err
+	}
+	s.RawImage.Store(img)
+
+	if s.Recorder != nil {
+		s.Recorder.Update(img, results, state, now)
+	}
+
+	overlay := Overlay{Zones: s.app.overlayZones(s.Device, zones), Lines: lines}
+	return drawDetectionResults(img, results, tracks, overlay), nil
+}
+
+/**
+ * Point in time view of a stream for the API.
+ */
+type StreamStatus struct {
+	Camera          CameraDevice `json:"camera"`
+	SignState       string       `json:"signState"`
+	SpeedLimit      int          `json:"speedLimit"`
+	FPS             float64      `json:"fps"`
+	InferenceMs     int64        `json:"inferenceMs"`
+	LastFrame       time.Time    `json:"lastFrame"`
+	Detections      []Detection  `json:"detections"`
+	Tracks          int          `json:"tracks"`
+	StoppedVehicles int          `json:"stoppedVehicles"`
+	WrongWay        int          `json:"wrongWay"`
+	Hazards         int          `json:"hazards"`
+
+	state SignState // SignState as value, for the UI
+}
+
+func (s *CameraStream) Status() StreamStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return StreamStatus{
+		Camera:          s.Device,
+		SignState:       s.SignState.String(),
+		SpeedLimit:      s.SpeedLimit,
+		FPS:             s.FPS,
+		InferenceMs:     s.InferenceTime.Milliseconds(),
+		LastFrame:       s.lastImage,
+		Detections:      s.Detections,
+		Tracks:          len(s.Tracks),
+		StoppedVehicles: len(s.StoppedTracks),
+		WrongWay:        len(s.WrongWayTracks),
+		Hazards:         len(s.HazardTracks),
+		state:           s.SignState,
+	}
+}
+
+/**
+ * Streams sorted by camera id.
+ * @return []*CameraStream
+ */
+func (app *App) activeStreams() []*CameraStream {
+	app.StreamsMu.Lock()
+	defer app.StreamsMu.Unlock()
+
+	streams := make([]*CameraStream, 0, len(app.Streams))
+	for _, stream := range app.Streams {
+		streams = append(streams, stream)
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].Device.ID < streams[j].Device.ID })
+	return streams
+}
+
+/**
+ * Camera focused in the Debug tab.
+ * @return int camera id, -1 when no camera is focused
+ */
+func (app *App) activeCamera() int {
+	app.StreamsMu.Lock()
+	defer app.StreamsMu.Unlock()
+	return app.ActiveCamera
+}
+
+/**
+ * Stream of the focused camera.
+ * @return *CameraStream, nil when the focused camera is not streamed
+ */
+func (app *App) focusedStream() *CameraStream {
+	app.StreamsMu.Lock()
+	defer app.StreamsMu.Unlock()
+	return app.Streams[app.ActiveCamera]
+}
+
+/**
+ * Start streaming a device, unless it is already streamed,
+ * and focus it in the Debug tab.
+ * @param *app, deviceID index into CameraDevices
+ */
+func startStream(app *App, deviceID int) {
+	if deviceID >= len(app.CameraDevices) {
+		return
+	}
+	device := app.CameraDevices[deviceID]
+	defer focusCamera(app, device.ID)
+
+	app.StreamsMu.Lock()
+	_, running := app.Streams[device.ID]
+	app.StreamsMu.Unlock()
+	if running {
+		return
+	}
+
+	// if problems with opening video, try different backend. V4L2 works for now.
+	cam, err := gocv.VideoCaptureFileWithAPI(device.Path, gocv.VideoCaptureV4L2)
+	if err != nil {
+		app.UI.SetStatus(fmt.Sprintf("Error opening device %s", device.Name))
+		app.emitEvent(Event{Type: EventCameraFault, CameraID: device.ID, Message: fmt.Sprintf("failed to open %s: %v", device.Path, err)})
+		return
+	}
+
+	stream := NewCameraStream(app, device)
+	app.StreamsMu.Lock()
+	select {
+	case <-app.Done:
+		// shutting down
+		app.StreamsMu.Unlock()
+		cam.Close()
+		return
+	default:
+	}
+	app.Streams[device.ID] = stream
+	app.Workers.Add(1)
+	app.StreamsMu.Unlock()
+
+	go stream.capture(cam)
+	app.UI.StreamsChanged()
+	app.UI.SetStatus(fmt.Sprintf("Streaming %d cameras", len(app.activeStreams())))
+}
+
+/**
+ * Stop streaming a camera.
+ * @param *app, cameraID
+ */
+func stopStream(app *App, cameraID int) {
+	app.StreamsMu.Lock()
+	stream, ok := app.Streams[cameraID]
+	delete(app.Streams, cameraID)
+	if ok && stream.Recorder != nil {
+		app.Workers.Add(1)
+	}
+	app.StreamsMu.Unlock()
+
+	if !ok {
+		return
+	}
+	close(stream.stop)
+	if stream.Recorder != nil {
+		// a clip still in post-roll is written, not dropped
+		go func() {
+			defer app.Workers.Done()
+			stream.Recorder.Close()
+		}()
+	}
+	metricCameraUp.WithLabelValues(cameraLabel(stream.Device)).Set(0)
+	app.UI.StreamsChanged()
+	app.UI.SetStatus(fmt.Sprintf("Stopped %s", stream.Device.Name))
+	updateSignState(app)
+}
+
+/**
+ * Show a camera in the Debug tab.
+ * @param *app, cameraID
+ */
+func focusCamera(app *App, cameraID int) {
+	app.StreamsMu.Lock()
+	app.ActiveCamera = cameraID
+	app.StreamsMu.Unlock()
+	if stream := app.focusedStream(); stream != nil {
+		if img := stream.CurrentImage.Load(); img != nil {
+			app.CurrentImage.Store(img)
+		}
+	}
+	app.UI.ShowFrame(app.focusedStream())
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image"
+	"image/draw"
+)
+
+// larger overlaps make the tiles nearly identical
+const maxTileOverlap = 0.9
+
+/**
+ * Sliced inference settings of a camera. The frame, or the bounding
+ * box of the listed zones, is split into overlapping tiles that are
+ * each run through the models at full model resolution, which keeps
+ * distant objects from shrinking to a few pixels.
+ */
+type TilingConfig struct {
+	Enabled bool `json:"enabled"`
+	Rows    int  `json:"rows"`
+	Cols    int  `json:"cols"`
+	// overlap between neighbouring tiles, fraction of the tile size
+	// from 0 to maxTileOverlap
+	Overlap float32 `json:"overlap"`
+	// only tile the area covered by these zones, empty tiles the whole frame
+	Zones []string `json:"zones"`
+	// also run the whole frame so large objects near the camera are not cut
+	FullFrame bool `json:"fullFrame"`
+}
+
+/**
+ * Regions of the frame to run the models on.
+ * @param bounds image.Rectangle of the frame, zones []Zone of the camera
+ * @return []image.Rectangle
+ */
+func (t *TilingConfig) Regions(bounds image.Rectangle, zones []Zone) []image.Rectangle {
+	if t == nil || !t.Enabled || t.Rows <= 0 || t.Cols <= 0 {
+		return []image.Rectangle{bounds}
+	}
+
+	area := bounds
+	if len(t.Zones) > 0 {
+		area = image.Rectangle{}
+		for _, name := range t.Zones {
+			if zone, ok := findZone(zones, name); ok {
+				area = area.Union(zoneBounds(zone, bounds))
+			}
+		}
+		if area.Empty() {
+			area = bounds
+		}
+	}
+
+	var regions []image.Rectangle
+	if t.FullFrame {
+		regions = append(regions, bounds)
+	}
+	return append(regions, tileRects(area, t.Rows, t.Cols, t.Overlap)...)
+}
+
+/**
+ * Split an area into rows x cols tiles that overlap by the given
+ * fraction of the tile size, clamped to [0, maxTileOverlap].
+ * @param area image.Rectangle, rows, cols int, overlap float32
+ * @return []image.Rectangle
+ */
+func tileRects(area image.Rectangle, rows, cols int, overlap float32) []image.Rectangle {
+	if overlap < 0 {
+		overlap = 0
+	} else if overlap > maxTileOverlap {
+		overlap = maxTileOverlap
+	}
+
+	// tile size so that n tiles with overlap cover the area exactly
+	tileW := float32(area.Dx()) / (float32(cols) - float32(cols-1)*overlap)
+	tileH := float32(area.Dy()) / (float32(rows) - float32(rows-1)*overlap)
+	stepX := tileW * (1 - overlap)
+	stepY := tileH * (1 - overlap)
+
+	var tiles []image.Rectangle
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			x := area.Min.X + int(float32(c)*stepX)
+			y := area.Min.Y + int(float32(r)*stepY)
+			tile := image.Rect(x, y, x+int(tileW), y+int(tileH)).Intersect(area)
+			if !tile.Empty() {
+				tiles = append(tiles, tile)
+			}
+		}
+	}
+	return tiles
+}
+
+func zoneBounds(zone Zone, frame image.Rectangle) image.Rectangle {
+	var rect image.Rectangle
+	for i, p := range zone.Polygon {
+		pt := image.Pt(frame.Min.X+int(p.X*float32(frame.Dx())), frame.Min.Y+int(p.Y*float32(frame.Dy())))
+		if i == 0 {
+			rect = image.Rectangle{Min: pt, Max: pt}
+			continue
+		}
+		rect = rect.Union(image.Rectangle{Min: pt, Max: pt.Add(image.Pt(1, 1))})
+	}
+	return rect.Intersect(frame)
+}
+
+/**
+ * Copy a region of the frame into its own image. Sub images can not
+ * be used directly because gocv expects a contiguous pixel buffer.
+ * @param img image.Image, region image.Rectangle
+ * @return image.Image
+ */
+func cropImage(img image.Image, region image.Rectangle) image.Image {
+	if region == img.Bounds() {
+		return img
+	}
+	crop := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(crop, crop.Bounds(), img, region.Min, draw.Src)
+	return crop
+}
+
+/**
+ * Map detections of a region back into the detection space of the frame.
+ * @param detections []Detection in the region's detection space
+ * @param region, frame image.Rectangle in pixels
+ * @return []Detection
+ */
+func mapToFrame(detections []Detection, region, frame image.Rectangle) []Detection {
+	if region == frame {
+		return detections
+	}
+
+	scaleX := float32(region.Dx()) / float32(frame.Dx())
+	scaleY := float32(region.Dy()) / float32(frame.Dy())
+	offsetX := float32(region.Min.X-frame.Min.X) / float32(frame.Dx()) * InputSize
+	offsetY := float32(region.Min.Y-frame.Min.Y) / float32(frame.Dy()) * InputSize
+
+	for i := range detections {
+		b := &detections[i].BBox
+		b.XMin = b.XMin*scaleX + offsetX
+		b.XMax = b.XMax*scaleX + offsetX
+		b.YMin = b.YMin*scaleY + offsetY
+		b.YMax = b.YMax*scaleY + offsetY
+	}
+	return detections
+}
+
+/**
+ * Tiling settings of a camera.
+ * @param CameraDevice
+ * @return *TilingConfig, nil when tiling is not configured
+ */
+func (app *App) cameraTiling(device CameraDevice) *TilingConfig {
+	if camera, ok := app.cameraConfig(device); ok {
+		return camera.Tiling
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	"testing"
+)
+
+func TestTileRects(t *testing.T) {
+	tests := []struct {
+		name       string
+		area       image.Rectangle
+		rows, cols int
+		overlap    float32
+		want       []image.Rectangle
+	}{
+		{
+			name: "single tile",
+			area: image.Rect(0, 0, 1280, 720),
+			rows: 1, cols: 1,
+			want: []image.Rectangle{image.Rect(0, 0, 1280, 720)},
+		},
+		{
+			name: "no overlap",
+			area: image.Rect(0, 0, 1280, 720),
+			rows: 1, cols: 2,
+			want: []image.Rectangle{image.Rect(0, 0, 640, 720), image.Rect(640, 0, 1280, 720)},
+		},
+		{
+			name: "overlap of a quarter",
+			area: image.Rect(0, 0, 700, 400),
+			rows: 1, cols: 2, overlap: 0.25,
+			want: []image.Rectangle{image.Rect(0, 0, 400, 400), image.Rect(300, 0, 700, 400)},
+		},
+		{
+			name: "offset area",
+			area: image.Rect(100, 200, 500, 400),
+			rows: 2, cols: 2,
+			want: []image.Rectangle{
+				image.Rect(100, 200, 300, 300), image.Rect(300, 200, 500, 300),
+				image.Rect(100, 300, 300, 400), image.Rect(300, 300, 500, 400),
+			},
+		},
+		{
+			name: "negative overlap is clamped to zero",
+			area: image.Rect(0, 0, 1280, 720),
+			rows: 1, cols: 2, overlap: -0.5,
+			want: []image.Rectangle{image.Rect(0, 0, 640, 720), image.Rect(640, 0, 1280, 720)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := tileRects(test.area, test.rows, test.cols, test.overlap)
+			if len(got) != len(test.want) {
+				t.Fatalf("tileRects() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("tile %d = %v, want %v", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTileRectsLargeOverlap(t *testing.T) {
+	area := image.Rect(0, 0, 1000, 500)
+	for _, overlap := range []float32{maxTileOverlap, 1, 5} {
+		tiles := tileRects(area, 2, 3, overlap)
+		if len(tiles) != 6 {
+			t.Fatalf("overlap %v: got %d tiles, want 6", overlap, len(tiles))
+		}
+		var covered image.Rectangle
+		for _, tile := range tiles {
+			if !tile.In(area) {
+				t.Errorf("overlap %v: tile %v outside %v", overlap, tile, area)
+			}
+			covered = covered.Union(tile)
+		}
+		// rounding may lose a pixel at the far edges
+		if covered.Dx() < area.Dx()-1 || covered.Dy() < area.Dy()-1 {
+			t.Errorf("overlap %v: tiles cover %v, want %v", overlap, covered, area)
+		}
+		if tiles[0] == tiles[1] {
+			t.Errorf("overlap %v: neighbouring tiles are identical", overlap)
+		}
+	}
+}
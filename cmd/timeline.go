@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+	"gocv.io/x/gocv"
+)
+
+const (
+	timelineTimeFormat = "2006-01-02 15:04"
+	allFilter          = "All"
+	// how far a snapshot may be from an event to be shown for it
+	snapshotMatchWindow = 30 * time.Second
+	maxTimelineEvents   = 5000
+)
+
+/**
+ * Timeline tab listing stored events. Selecting an event shows its
+ * clip, replayed with the detection overlay, or its snapshot.
+ */
+type Timeline struct {
+	Content fyne.CanvasObject
+
+	app    *App
+	events []Event
+	list   *widget.List
+
+	typeSelect   *widget.Select
+	cameraSelect *widget.Select
+	fromEntry    *widget.Entry
+	toEntry      *widget.Entry
+
+	preview *canvas.Image
+	details *widget.Label
+	player  *ClipPlayer
+}
+
+func NewTimeline(app *App) *Timeline {
+	t := &Timeline{app: app}
+
+	types := []string{allFilter}
+	for _, eventType := range []EventType{EventSignState, EventSpeedLimit, EventStoppedVehicle, EventWrongWay, EventHazard, EventCameraFault, EventModelReload, EventOverride} {
+		types = append(types, string(eventType))
+	}
+	t.typeSelect = widget.NewSelect(types, nil)
+	t.typeSelect.SetSelected(allFilter)
+	t.cameraSelect = widget.NewSelect([]string{allFilter}, nil)
+	t.cameraSelect.SetSelected(allFilter)
+
+	t.fromEntry = widget.NewEntry()
+	t.fromEntry.SetText(time.Now().Add(-24 * time.Hour).Format(timelineTimeFormat))
+	t.toEntry = widget.NewEntry()
+	t.toEntry.SetPlaceHolder("now")
+
+	t.preview = canvas.NewImageFromImage(nil)
+	t.preview.FillMode = canvas.ImageFillContain
+	t.details = widget.NewLabel("Select an event")
+
+	t.list = widget.NewList(
+		func() int { return len(t.events) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			event := t.events[id]
+			item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  cam %d  %s",
+				event.Time.Format(time.DateTime), event.Type, event.CameraID, event.Message))
+		},
+	)
+	t.list.OnSelected = t.show
+
+	filters := container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Type", t.typeSelect),
+			widget.NewFormItem("Camera", t.cameraSelect),
+			widget.NewFormItem("From", t.fromEntry),
+			widget.NewFormItem("To", t.toEntry),
+		),
+		widget.NewButton("Search", t.Reload),
+	)
+
+	playBtn := widget.NewButton("Play", func() {
+		if t.player != nil {
+			t.player.Play()
+		}
+	})
+	pauseBtn := widget.NewButton("Pause", func() {
+		if t.player != nil {
+			t.player.Pause()
+		}
+	})
+	stepBtn := widget.NewButton("Next Frame", func() {
+		if t.player != nil {
+			t.player.Pause()
+			t.player.Step()
+		}
+	})
+	playback := container.NewGridWithColumns(3, playBtn, pauseBtn, stepBtn)
+
+	preview := container.NewBorder(nil, container.NewVBox(playback, t.details), nil, nil, t.preview)
+	split := container.NewHSplit(container.NewBorder(filters, nil, nil, nil, t.list), preview)
+	split.Offset = 0.4
+	t.Content = split
+
+	return t
+}
+
+/**
+ * Run the query of the current filters against the event store.
+ */
+func (t *Timeline) Reload() {
+	if t.app.EventStore == nil {
+		t.details.SetText("Event store not open")
+		return
+	}
+
+	// cameras may have been found since the last search
+	cameras := []string{allFilter}
+	for _, device := range t.app.CameraDevices {
+		cameras = append(cameras, fmt.Sprintf("%d: %s", device.ID, device.Name))
+	}
+	t.cameraSelect.Options = cameras
+
+	query := EventQuery{Limit: maxTimelineEvents}
+	if t.typeSelect.Selected != allFilter {
+		query.Types = []EventType{EventType(t.typeSelect.Selected)}
+	}
+	if selected := t.cameraSelect.Selected; selected != allFilter {
+		var id int
+		if _, err := fmt.Sscanf(selected, "%d:", &id); err == nil {
+			query.CameraID = &id
+		}
+	}
+
+	var err error
+	if query.From, err = parseTimelineTime(t.fromEntry.Text); err != nil {
+		t.details.SetText(err.Error())
+		return
+	}
+	if query.To, err = parseTimelineTime(t.toEntry.Text); err != nil {
+		t.details.SetText(err.Error())
+		return
+	}
+
+	events, err := t.app.EventStore.Query(query)
+	if err != nil {
+		t.details.SetText(err.Error())
+		return
+	}
+	// newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	t.events = events
+	t.list.UnselectAll()
+	t.list.Refresh()
+	t.details.SetText(fmt.Sprintf("%d events", len(events)))
+}
+
+func parseTimelineTime(text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.ParseInLocation(timelineTimeFormat, text, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time should look like %s", timelineTimeFormat)
+	}
+	return parsed, nil
+}
+
+func (t *Timeline) show(id widget.ListItemID) {
+	if id >= len(t.events) {
+		return
+	}
+	event := t.events[id]
+
+	if t.player != nil {
+		t.player.Close()
+		t.player = nil
+	}
+	t.preview.Image = nil
+	t.preview.File = ""
+
+	text := fmt.Sprintf("%s %s camera %d: %s", event.Time.Format(time.DateTime), event.Type, event.CameraID, event.Message)
+
+	if clip, ok := t.findClip(event); ok {
+		player, err := OpenClipPlayer(clip, func(img image.Image, frame int) {
+			t.preview.Image = img
+			t.preview.Refresh()
+			t.details.SetText(fmt.Sprintf("%s\nclip %s, frame %d/%d", text, clip.Path, frame+1, len(clip.Metadata.Frames)))
+		})
+		if err == nil {
+			t.player = player
+			player.Step()
+			return
+		}
+		text += "\n" + err.Error()
+	}
+
+	if snapshot, ok := t.findSnapshot(event); ok {
+		t.preview.File = snapshot.AnnotatedPath
+		text += "\nsnapshot " + snapshot.AnnotatedPath
+	} else {
+		text += "\nno clip or snapshot"
+	}
+	t.preview.Refresh()
+	t.details.SetText(text)
+}
+
+/**
+ * Clip of the event camera that covers the event time.
+ */
+func (t *Timeline) findClip(event Event) (Clip, bool) {
+	clips, err := loadClips(t.app.Config.Recording.Dir)
+	if err != nil {
+		return Clip{}, false
+	}
+	for _, clip := range clips {
+		start, end := clip.Span()
+		if clip.Metadata.CameraID == event.CameraID && !event.Time.Before(start) && !event.Time.After(end) {
+			return clip, true
+		}
+	}
+	return Clip{}, false
+}
+
+/**
+ * Snapshot of the event camera closest to the event time.
+ */
+func (t *Timeline) findSnapshot(event Event) (Snapshot, bool) {
+	snapshots, err := loadSnapshots(t.app.Config.Snapshots.Dir)
+	if err != nil {
+		return Snapshot{}, false
+	}
+
+	var best Snapshot
+	bestDiff := snapshotMatchWindow + 1
+	for _, snapshot := range snapshots {
+		if snapshot.CameraID != event.CameraID {
+			continue
+		}
+		diff := snapshot.Time.Sub(event.Time).Abs()
+		if diff <= snapshotMatchWindow && diff < bestDiff {
+			best, bestDiff = snapshot, diff
+		}
+	}
+	return best, bestDiff <= snapshotMatchWindow
+}
+
+/**
+ * Plays a recorded clip, drawing the stored detections of every
+ * frame over it the same way as on the live feed.
+ */
+type ClipPlayer struct {
+	Clip Clip
+
+	mu      sync.Mutex
+	video   *gocv.VideoCapture
+	frame   gocv.Mat
+	index   int
+	stop    chan bool
+	onFrame func(img image.Image, frame int)
+}
+
+func OpenClipPlayer(clip Clip, onFrame func(img image.Image, frame int)) (*ClipPlayer, error) {
+	video, err := gocv.VideoCaptureFile(clip.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open clip %s: %v", clip.Path, err)
+	}
+	return &ClipPlayer{Clip: clip, video: video, frame: gocv.NewMat(), onFrame: onFrame}, nil
+}
+
+/**
+ * Show the next frame.
+ * @return bool false at the end of the clip
+ */
+func (p *ClipPlayer) Step() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.video == nil {
+		return false
+	}
+	if ok := p.video.Read(&p.frame); !ok || p.frame.Empty() {
+		return false
+	}
+	img, err := p.frame.ToImage()
+	if err != nil {
+		return false
+	}
+
+	if p.index < len(p.Clip.Metadata.Frames) {
+		img = drawDetectionResults(img, p.Clip.Metadata.Frames[p.index].Detections, nil, Overlay{})
+	}
+	p.onFrame(img, p.index)
+	p.index++
+	return true
+}
+
+/**
+ * Play from the current frame at the clip frame rate.
+ */
+func (p *ClipPlayer) Play() {
+	p.Pause()
+
+	fps := p.Clip.Metadata.FPS
+	if fps <= 0 {
+		fps = 10
+	}
+	stop := make(chan bool)
+	p.mu.Lock()
+	p.stop = stop
+	p.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / fps))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if !p.Step() {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (p *ClipPlayer) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+}
+
+func (p *ClipPlayer) Close() {
+	p.Pause()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.video != nil {
+		p.video.Close()
+		p.video = nil
+	}
+	p.frame.Close()
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+/**
+ * A single object followed across frames.
+ * BBox is the Kalman filtered box, History holds the raw matched
+ * boxes (newest last) so motion can be analysed by the sign logic.
+ */
+type Track struct {
+	ID         int
+	ClassID    int
+	ClassName  string
+	Confidence float32
+	BBox       BoundingBox
+	Zones      []string // zones of the latest matched detection
+	Lane       string
+	Speed      float64 // km/h, 0 when the camera is not calibrated
+	Age        int     // frames since the track was created
+	Hits       int     // frames where a detection was matched
+	Missed     int     // consecutive frames without a matched detection
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	History    []TrackPoint
+
+	filter boxFilter
+}
+
+type TrackPoint struct {
+	Time time.Time
+	BBox BoundingBox
+}
+
+/**
+ * SORT style tracker with ByteTrack's second association round.
+ * High confidence detections are matched first against all predicted
+ * tracks, low confidence ones are then used only to keep existing
+ * tracks alive, which helps with partially occluded vehicles.
+ */
+type Tracker struct {
+	IoUThreshold     float32 // minimum IoU for a match
+	LowIoUThreshold  float32 // minimum IoU when matching low confidence detections
+	HighConfidence   float32 // detections above this may start new tracks
+	MinHits          int     // hits before a track is reported
+	MaxMissed        int     // frames a lost track is kept for
+	MaxHistoryLength int
+
+	tracks []*Track
+	nextID int
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		IoUThreshold:     0.3,
+		LowIoUThreshold:  0.5,
+		HighConfidence:   0.5,
+		MinHits:          3,
+		MaxMissed:        30,
+		MaxHistoryLength: 300,
+		nextID:           1,
+	}
+}
+
+/**
+ * Predict every track forward, associate the new detections and
+ * return the confirmed tracks that were seen on this frame.
+ * @param detections []Detection, now time.Time
+ * @return []*Track
+ */
+func (t *Tracker) Update(detections []Detection, now time.Time) []*Track {
+	for _, track := range t.tracks {
+		track.filter.predict()
+		track.BBox = track.filter.box()
+		track.Age++
+		track.Missed++
+	}
+
+	var high, low []Detection
+	for _, det := range detections {
+		if det.Confidence >= t.HighConfidence {
+			high = append(high, det)
+		} else {
+			low = append(low, det)
+		}
+	}
+
+	unmatchedTracks, unmatchedHigh := t.associate(t.tracks, high, t.IoUThreshold, now)
+
+	// second round only keeps tracks alive, low scores never start new tracks
+	var confirmed []*Track
+	for _, track := range unmatchedTracks {
+		if track.Hits >= t.MinHits {
+			confirmed = append(confirmed, track)
+		}
+	}
+	t.associate(confirmed, low, t.LowIoUThreshold, now)
+
+	for _, det := range unmatchedHigh {
+		t.tracks = append(t.tracks, t.newTrack(det, now))
+	}
+
+	var alive []*Track
+	var visible []*Track
+	for _, track := range t.tracks {
+		if track.Missed > t.MaxMissed {
+			continue
+		}
+		alive = append(alive, track)
+		if track.Missed == 0 && track.Hits >= t.MinHits {
+			visible = append(visible, track)
+		}
+	}
+	t.tracks = alive
+
+	return visible
+}
+
+/**
+ * Confirmed tracks that are still alive, including the ones
+ * that were not matched on the latest frame.
+ * @return []*Track
+ */
+func (t *Tracker) Tracks() []*Track {
+	var confirmed []*Track
+	for _, track := range t.tracks {
+		if track.Hits >= t.MinHits {
+			confirmed = append(confirmed, track)
+		}
+	}
+	return confirmed
+}
+
+/**
+ * Match detections to tracks with the Hungarian algorithm on 1-IoU cost.
+ * Matched tracks are updated in place.
+ * @return unmatched tracks and unmatched detections
+ */
+func (t *Tracker) associate(tracks []*Track, detections []Detection, iouThreshold float32, now time.Time) ([]*Track, []Detection) {
+	if len(tracks) == 0 || len(detections) == 0 {
+		return tracks, detections
+	}
+
+	cost := make([][]float64, len(tracks))
+	for i, track := range tracks {
+		cost[i] = make([]float64, len(detections))
+		for j, det := range detections {
+			if track.ClassName != det.ClassName {
+				cost[i][j] = 1
+				continue
+			}
+			cost[i][j] = float64(1 - calculateIoU(track.BBox, det.BBox))
+		}
+	}
+
+	assignment := hungarian(cost)
+
+	matchedDetections := make([]bool, len(detections))
+	var unmatchedTracks []*Track
+	for i, j := range assignment {
+		if j < 0 || 1-cost[i][j] < float64(iouThreshold) {
+			unmatchedTracks = append(unmatchedTracks, tracks[i])
+			continue
+		}
+		matchedDetections[j] = true
+		tracks[i].update(detections[j], now, t.MaxHistoryLength)
+	}
+
+	var unmatchedDetections []Detection
+	for j, det := range detections {
+		if !matchedDetections[j] {
+			unmatchedDetections = append(unmatchedDetections, det)
+		}
+	}
+
+	return unmatchedTracks, unmatchedDetections
+}
+
+func (t *Tracker) newTrack(det Detection, now time.Time) *Track {
+	track := &Track{
+		ID:         t.nextID,
+		ClassID:    det.ClassID,
+		ClassName:  det.ClassName,
+		Confidence: det.Confidence,
+		BBox:       det.BBox,
+		Zones:      det.Zones,
+		Lane:       det.Lane,
+		Hits:       1,
+		FirstSeen:  now,
+		LastSeen:   now,
+		History:    []TrackPoint{{Time: now, BBox: det.BBox}},
+		filter:     newBoxFilter(det.BBox),
+	}
+	t.nextID++
+	return track
+}
+
+func (track *Track) update(det Detection, now time.Time, maxHistory int) {
+	track.filter.correct(det.BBox)
+	track.BBox = track.filter.box()
+	track.Confidence = det.Confidence
+	track.Zones = det.Zones
+	track.Lane = det.Lane
+	track.Hits++
+	track.Missed = 0
+	track.LastSeen = now
+
+	track.History = append(track.History, TrackPoint{Time: now, BBox: det.BBox})
+	if len(track.History) > maxHistory {
+		track.History = track.History[len(track.History)-maxHistory:]
+	}
+}
+
+/**
+ * Center of the box bottom edge, which is where the
+ * object touches the road.
+ */
+func (b BoundingBox) Anchor() (float32, float32) {
+	return (b.XMin + b.XMax) / 2, b.YMax
+}
+
+func (b BoundingBox) Center() (float32, float32) {
+	return (b.XMin + b.XMax) / 2, (b.YMin + b.YMax) / 2
+}
+
+/**
+ * Constant velocity Kalman filter for one box coordinate.
+ * The coordinates of a box are filtered independently, which is
+ * equivalent to the full SORT filter with diagonal noise matrices.
+ */
+type kalman1D struct {
+	x, v          float32 // position and velocity per frame
+	p00, p01, p11 float32 // covariance
+}
+
+const (
+	kalmanProcessNoise     = 1.0
+	kalmanMeasurementNoise = 10.0
+)
+
+func newKalman1D(x float32) kalman1D {
+	// velocity is unknown on the first frame
+	return kalman1D{x: x, p00: kalmanMeasurementNoise, p11: 1000}
+}
+
+func (k *kalman1D) predict() {
+	k.x += k.v
+	k.p00 += 2*k.p01 + k.p11 + kalmanProcessNoise
+	k.p01 += k.p11
+	k.p11 += kalmanProcessNoise
+}
+
+func (k *kalman1D) correct(z float32) {
+	s := k.p00 + kalmanMeasurementNoise
+	k0 := k.p00 / s
+	k1 := k.p01 / s
+	residual := z - k.x
+
+	k.x += k0 * residual
+	k.v += k1 * residual
+
+	p01 := k.p01
+	k.p00 -= k0 * k.p00
+	k.p01 -= k0 * p01
+	k.p11 -= k1 * p01
+}
+
+// filters box center, width and height
+type boxFilter [4]kalman1D
+
+func newBoxFilter(b BoundingBox) boxFilter {
+	cx, cy := b.Center()
+	return boxFilter{
+		newKalman1D(cx),
+		newKalman1D(cy),
+		newKalman1D(b.XMax - b.XMin),
+		newKalman1D(b.YMax - b.YMin),
+	}
+}
+
+func (f *boxFilter) predict() {
+	for i := range f {
+		f[i].predict()
+	}
+}
+
+func (f *boxFilter) correct(b BoundingBox) {
+	cx, cy := b.Center()
+	f[0].correct(cx)
+	f[1].correct(cy)
+	f[2].correct(b.XMax - b.XMin)
+	f[3].correct(b.YMax - b.YMin)
+}
+
+func (f *boxFilter) box() BoundingBox {
+	w := max(f[2].x, 1)
+	h := max(f[3].x, 1)
+	return BoundingBox{
+		XMin: f[0].x - w/2,
+		YMin: f[1].x - h/2,
+		XMax: f[0].x + w/2,
+		YMax: f[1].x + h/2,
+	}
+}
+
+/**
+ * Minimum cost assignment (Hungarian / Kuhn-Munkres) for a rectangular
+ * cost matrix. The matrix is padded to a square internally.
+ * @param cost [][]float64 rows x cols
+ * @return assignment per row, -1 when the row is left unassigned
+ */
+func hungarian(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	n := rows
+	if cols > n {
+		n = cols
+	}
+
+	// 1-indexed potentials as in the classic formulation
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+
+	at := func(i, j int) float64 {
+		if i <= rows && j <= cols {
+			return cost[i-1][j-1]
+		}
+		return 0
+	}
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				cur := at(i0, j) - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+			if j0 == 0 {
+				break
+			}
+		}
+	}
+
+	assignment := make([]int, rows)
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for j := 1; j <= n; j++ {
+		if p[j] > 0 && p[j] <= rows && j <= cols {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{
+			name: "empty",
+			cost: nil,
+			want: nil,
+		},
+		{
+			name: "diagonal",
+			cost: [][]float64{
+				{0, 1, 1},
+				{1, 0, 1},
+				{1, 1, 0},
+			},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "greedy choice is not optimal",
+			cost: [][]float64{
+				{1, 2},
+				{2, 10},
+			},
+			want: []int{1, 0},
+		},
+		{
+			name: "more rows than columns",
+			cost: [][]float64{
+				{0.9, 0.1},
+				{0.1, 0.9},
+				{0.5, 0.5},
+			},
+			want: []int{1, 0, -1},
+		},
+		{
+			name: "more columns than rows",
+			cost: [][]float64{
+				{0.9, 0.8, 0.1},
+			},
+			want: []int{2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := hungarian(test.cost)
+			if len(got) != len(test.want) {
+				t.Fatalf("hungarian() = %v, want %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("hungarian() = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestKalman1DFollowsConstantVelocity(t *testing.T) {
+	filter := newKalman1D(0)
+	for frame := 1; frame <= 30; frame++ {
+		filter.predict()
+		filter.correct(float32(frame * 5))
+	}
+
+	if math.Abs(float64(filter.v-5)) > 0.5 {
+		t.Errorf("velocity = %.2f, want about 5", filter.v)
+	}
+	filter.predict()
+	if math.Abs(float64(filter.x-155)) > 2 {
+		t.Errorf("predicted position = %.2f, want about 155", filter.x)
+	}
+}
+
+func testDetection(className string, confidence float32, x, y float32) Detection {
+	return Detection{
+		ClassName:  className,
+		Confidence: confidence,
+		BBox:       BoundingBox{XMin: x, YMin: y, XMax: x + 50, YMax: y + 30},
+	}
+}
+
+func TestTrackerKeepsIDs(t *testing.T) {
+	tracker := NewTracker()
+	start := time.Now()
+
+	var tracks []*Track
+	for frame := 0; frame < 10; frame++ {
+		now := start.Add(time.Duration(frame) * 100 * time.Millisecond)
+		offset := float32(frame * 4)
+		tracks = tracker.Update([]Detection{
+			testDetection("car", 0.9, 100+offset, 100),
+			testDetection("car", 0.9, 400-offset, 300),
+		}, now)
+	}
+
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+	ids := map[int]bool{}
+	for _, track := range tracks {
+		ids[track.ID] = true
+		if track.Hits != 10 {
+			t.Errorf("track %d has %d hits, want 10", track.ID, track.Hits)
+		}
+	}
+	if !ids[1] || !ids[2] {
+		t.Errorf("track ids = %v, want 1 and 2", ids)
+	}
+}
+
+func TestTrackerMinHitsAndMaxMissed(t *testing.T) {
+	tracker := NewTracker()
+	tracker.MaxMissed = 2
+	now := time.Now()
+	det := testDetection("car", 0.9, 100, 100)
+
+	for frame := 1; frame <= tracker.MinHits; frame++ {
+		tracks := tracker.Update([]Detection{det}, now)
+		if want := frame >= tracker.MinHits; (len(tracks) == 1) != want {
+			t.Fatalf("frame %d: got %d tracks, reported %v", frame, len(tracks), want)
+		}
+	}
+
+	for frame := 1; frame <= tracker.MaxMissed; frame++ {
+		tracker.Update(nil, now)
+		if len(tracker.Tracks()) != 1 {
+			t.Fatalf("track dropped after %d missed frames, MaxMissed is %d", frame, tracker.MaxMissed)
+		}
+	}
+	tracker.Update(nil, now)
+	if len(tracker.Tracks()) != 0 {
+		t.Errorf("track kept after %d missed frames", tracker.MaxMissed+1)
+	}
+}
+
+func TestTrackerLowConfidence(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+
+	// low confidence detections never start a track
+	tracker.Update([]Detection{testDetection("car", 0.2, 100, 100)}, now)
+	if len(tracker.tracks) != 0 {
+		t.Fatalf("low confidence detection started a track")
+	}
+
+	// but keep a confirmed one alive
+	for i := 0; i < tracker.MinHits; i++ {
+		tracker.Update([]Detection{testDetection("car", 0.9, 100, 100)}, now)
+	}
+	tracks := tracker.Update([]Detection{testDetection("car", 0.2, 101, 100)}, now)
+	if len(tracks) != 1 || tracks[0].Missed != 0 {
+		t.Errorf("low confidence detection did not update the confirmed track")
+	}
+}
+
+func TestTrackerSeparatesClasses(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+	for i := 0; i < tracker.MinHits; i++ {
+		tracker.Update([]Detection{testDetection("car", 0.9, 100, 100)}, now)
+	}
+	tracker.Update([]Detection{testDetection("person", 0.9, 100, 100)}, now)
+
+	if len(tracker.tracks) != 2 {
+		t.Errorf("got %d tracks, want a new track for the other class", len(tracker.tracks))
+	}
+}
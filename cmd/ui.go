@@ -1,18 +1,104 @@
 package main
 
 import (
+	"fmt"
 	"image"
+	"strconv"
+	"time"
 
 	"fyne.io/fyne/v2"
+	fyneapp "fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
-	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 )
 
-var speedSign *canvas.Image
+var signView *SignView
 
-var warningSign *canvas.Image
+/**
+ * The desktop window with the Signs, Debug, Timeline and Gallery tabs.
+ */
+type desktopUI struct {
+	app  *App
+	fyne fyne.App
+}
+
+/**
+ * Open the main window. Needs a display.
+ * @param *app
+ * @return UI
+ */
+func NewDesktopUI(app *App) UI {
+	a := fyneapp.New()
+	app.Window = a.NewWindow("SmartSign™")
+	SetupUI(app)
+	app.Window.Resize(fyne.NewSize(1280, 720))
+	app.Window.Show()
+	if app.Config.SignDisplay.Enabled {
+		openSignDisplay(app)
+	}
+	return &desktopUI{app: app, fyne: a}
+}
+
+func (d *desktopUI) SetStatus(text string) {
+	d.app.StatusLabel.SetText(text)
+}
+
+func (d *desktopUI) SetData(text string) {
+	d.app.DataLabel.SetText(text)
+}
+
+func (d *desktopUI) ShowDetections(stream *CameraStream) {
+	updateClassificationUI(d.app, stream)
+}
+
+func (d *desktopUI) ShowFrame(stream *CameraStream) {
+	if stream != nil {
+		if tile := stream.tile(); tile != nil {
+			tile.Refresh()
+		}
+	}
+	if stream == nil || stream.Device.ID == d.app.activeCamera() {
+		RefreshCanvas(d.app)
+	}
+}
+
+func (d *desktopUI) ShowSigns(state SignState, speedLimit int) {
+	UpdateSigns(state, speedLimit)
+	if display := d.app.signDisplay(); display != nil {
+		display.Update(state, speedLimit)
+	}
+}
+
+func (d *desktopUI) ShowOverride(override *Override) {
+	if d.app.OverrideLabel == nil {
+		return
+	}
+	if override != nil {
+		d.app.OverrideLabel.SetText(fmt.Sprintf("Override: %s\nby %s, %s\nuntil %s",
+			override.describe(), override.Source, override.Reason, override.Expires.Format(time.TimeOnly)))
+	} else {
+		d.app.OverrideLabel.SetText("Automatic")
+	}
+}
+
+func (d *desktopUI) CamerasChanged() {
+	UpdateDeviceList(d.app)
+}
+
+func (d *desktopUI) StreamsChanged() {
+	updateCameraGrid(d.app)
+}
+
+func (d *desktopUI) SnapshotsChanged() {
+	if d.app.Gallery != nil {
+		d.app.Gallery.Reload()
+	}
+}
+
+func (d *desktopUI) Run() {
+	d.fyne.Run()
+}
 
 /**
  * UI setup function called externally from main function.
@@ -32,11 +118,7 @@ func SetupUI(app *App) {
 
 	app.VideoCanvas.SetMinSize(fyne.NewSize(float32(videoWidth), float32(float32(videoWidth)/aspectRatio)))
 
-	speedSign = canvas.NewImageFromFile("./FyneTest/100Speed.png")
-	speedSign.FillMode = canvas.ImageFillContain
-
-	warningSign = canvas.NewImageFromFile("./FyneTest/Blank.png")
-	warningSign.FillMode = canvas.ImageFillContain
+	signView = NewSignView(app.currentSign())
 
 	app.StatusLabel = widget.NewLabel("Ready")
 	app.DeviceSelect = widget.NewSelect(nil, nil)
@@ -48,11 +130,86 @@ func SetupUI(app *App) {
 		go DetectCameras(app)
 	})
 
+	startAllBtn := widget.NewButton("Start All Cameras", func() {
+		focused := app.activeCamera()
+		for i := range app.CameraDevices {
+			startStream(app, i)
+		}
+		if focused >= 0 {
+			focusCamera(app, focused)
+		}
+	})
+
+	stopBtn := widget.NewButton("Stop Camera", func() {
+		stopStream(app, app.activeCamera())
+	})
+
+	snapshotBtn := widget.NewButton("Snapshot", func() {
+		stream := app.focusedStream()
+		if stream == nil {
+			app.StatusLabel.SetText("No camera streaming")
+			return
+		}
+		snapshot, err := stream.takeSnapshot("manual")
+		if err != nil {
+			app.StatusLabel.SetText(err.Error())
+			return
+		}
+		app.StatusLabel.SetText(fmt.Sprintf("Saved %s", snapshot.AnnotatedPath))
+	})
+
+	signDisplayBtn := widget.NewButton("Sign Display", func() {
+		if app.signDisplay() != nil {
+			closeSignDisplay(app)
+		} else {
+			openSignDisplay(app)
+		}
+	})
+
+	reloadBtn := widget.NewButton("Reload Models", func() {
+		go func() {
+			if err := app.reloadModels(); err != nil {
+				app.StatusLabel.SetText(err.Error())
+				return
+			}
+			app.StatusLabel.SetText("Models reloaded")
+		}()
+	})
+
+	exportEventsBtn := widget.NewButton("Export Events", func() {
+		if app.EventStore == nil {
+			app.StatusLabel.SetText("Event store not open")
+			return
+		}
+		path, err := app.EventStore.ExportCSV(EventQuery{})
+		if err != nil {
+			app.StatusLabel.SetText(err.Error())
+			return
+		}
+		app.StatusLabel.SetText(fmt.Sprintf("Exported %s", path))
+	})
+
+	app.GridCheck = widget.NewCheck("Camera Grid", func(grid bool) {
+		showCameraGrid(app, grid)
+	})
+
+	app.ZoneEditor = NewZoneEditor()
+
 	controls := container.NewVBox(
 		widget.NewLabel("Select Camera:"),
 		app.DeviceSelect,
 		refreshBtn,
+		container.NewGridWithColumns(2, startAllBtn, stopBtn),
+		app.GridCheck,
+		container.NewGridWithColumns(2, snapshotBtn, signDisplayBtn),
+		container.NewGridWithColumns(2, reloadBtn, exportEventsBtn),
 		app.StatusLabel,
+		widget.NewSeparator(),
+		ZoneControls(app),
+		widget.NewSeparator(),
+		CalibrationControls(app),
+		widget.NewSeparator(),
+		OverrideControls(app),
 	)
 	dataContainer := container.NewVBox(
 		widget.NewLabel("Data"),
@@ -61,18 +218,27 @@ func SetupUI(app *App) {
 		app.DataBody,
 	)
 
-	videoContainer := container.NewCenter(app.VideoCanvas)
+	app.VideoView = container.NewCenter(container.NewStack(app.VideoCanvas, app.ZoneEditor))
+	app.CameraGrid = container.NewStack()
+	app.CameraGrid.Hide()
+	videoContainer := container.NewStack(app.VideoView, app.CameraGrid)
 	content := container.NewVSplit(videoContainer, dataContainer)
 
-	split := container.NewHSplit(controls, content)
+	split := container.NewHSplit(container.NewVScroll(controls), content)
 	split.Offset = 0.2
 
 	app.Window.Resize(fyne.NewSize(1280, 720))
 	app.Window.SetFixedSize(false)
 
+	app.Gallery = NewGallery(app)
+	app.Timeline = NewTimeline(app)
+	app.Timeline.Reload()
+
 	tabs := container.NewAppTabs(
-		container.NewTabItem("Signs", container.New(layout.NewGridLayout(3), speedSign, warningSign)),
+		container.NewTabItem("Signs", signView.Content),
 		container.NewTabItem("Debug", split),
+		container.NewTabItem("Timeline", app.Timeline.Content),
+		container.NewTabItem("Gallery", app.Gallery.Content),
 	)
 	app.Window.SetContent(tabs)
 }
@@ -96,6 +262,143 @@ func UpdateDeviceList(app *App) {
 	app.DeviceSelect.Refresh()
 }
 
+func UpdateSigns(state SignState, speedLimit int) {
+	signView.Update(state, speedLimit)
+}
+
+/**
+ * Controls for drawing region of interest polygons over the video.
+ * Clicking the video while drawing adds a vertex to the zone.
+ * @param *app
+ * @return fyne.CanvasObject
+ */
+func ZoneControls(app *App) fyne.CanvasObject {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Zone name")
+
+	kindSelect := widget.NewSelect([]string{string(ZoneInclude), string(ZoneExclude), string(ZoneLane)}, nil)
+	kindSelect.SetSelected(string(ZoneInclude))
+
+	drawBtn := widget.NewButton("Draw Zone", func() {
+		name := nameEntry.Text
+		if name == "" {
+			device, _ := app.activeDevice()
+			name = fmt.Sprintf("%s %d", kindSelect.Selected, len(app.cameraZones(device))+1)
+		}
+		app.ZoneEditor.Start(name, ZoneKind(kindSelect.Selected))
+		app.StatusLabel.SetText("Click the video to add zone points")
+	})
+
+	finishBtn := widget.NewButton("Finish Zone", func() {
+		zone, ok := app.ZoneEditor.Finish()
+		device, found := app.activeDevice()
+		if !ok || !found || zone.Kind == ZoneCalibration {
+			app.StatusLabel.SetText("Zone needs a camera and at least 3 points")
+			return
+		}
+		app.updateCameraConfig(device, func(camera *CameraConfig) {
+			camera.Zones = append(camera.Zones, zone)
+		})
+		nameEntry.SetText("")
+		app.StatusLabel.SetText(fmt.Sprintf("Added zone %s", zone.Name))
+	})
+
+	clearBtn := widget.NewButton("Clear Zones", func() {
+		app.ZoneEditor.Finish()
+		if device, found := app.activeDevice(); found {
+			app.updateCameraConfig(device, func(camera *CameraConfig) {
+				camera.Zones = nil
+			})
+		}
+	})
+
+	saveBtn := widget.NewButton("Save Config", func() {
+		if err := app.saveConfig(); err != nil {
+			app.StatusLabel.SetText(err.Error())
+			return
+		}
+		app.StatusLabel.SetText("Config saved")
+	})
+
+	exportBtn := widget.NewButton("Export Counts", func() {
+		stream := app.focusedStream()
+		if stream == nil {
+			app.StatusLabel.SetText("No camera streaming")
+			return
+		}
+		path, err := stream.Counter.ExportCSV(stream.Device.Name)
+		if err != nil {
+			app.StatusLabel.SetText(err.Error())
+			return
+		}
+		app.StatusLabel.SetText(fmt.Sprintf("Exported %s", path))
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("Zones:"),
+		nameEntry,
+		kindSelect,
+		container.NewGridWithColumns(2, drawBtn, finishBtn),
+		container.NewGridWithColumns(2, clearBtn, saveBtn),
+		exportBtn,
+	)
+}
+
+/**
+ * Controls for the ground plane calibration used in speed estimation.
+ * Mark four road points around a rectangle of known size, starting
+ * near left and going around through near right, far right and far left.
+ * @param *app
+ * @return fyne.CanvasObject
+ */
+func CalibrationControls(app *App) fyne.CanvasObject {
+	widthEntry := widget.NewEntry()
+	widthEntry.SetPlaceHolder("Width across road (m)")
+	lengthEntry := widget.NewEntry()
+	lengthEntry.SetPlaceHolder("Length along road (m)")
+
+	markBtn := widget.NewButton("Mark Points", func() {
+		app.ZoneEditor.Start("calibration", ZoneCalibration)
+		app.StatusLabel.SetText("Click 4 road points: near left, near right, far right, far left")
+	})
+
+	applyBtn := widget.NewButton("Apply", func() {
+		zone, _ := app.ZoneEditor.Finish()
+		device, found := app.activeDevice()
+		if !found || len(zone.Polygon) != 4 {
+			app.StatusLabel.SetText("Calibration needs a camera and exactly 4 points")
+			return
+		}
+
+		width, errW := strconv.ParseFloat(widthEntry.Text, 32)
+		length, errL := strconv.ParseFloat(lengthEntry.Text, 32)
+		if errW != nil || errL != nil || width <= 0 || length <= 0 {
+			app.StatusLabel.SetText("Enter width and length in meters")
+			return
+		}
+
+		calibration := &Calibration{
+			ImagePoints: zone.Polygon,
+			WorldPoints: rectangleWorldPoints(float32(width), float32(length)),
+		}
+		if _, err := calibration.Homography(); err != nil {
+			app.StatusLabel.SetText(err.Error())
+			return
+		}
+		app.updateCameraConfig(device, func(camera *CameraConfig) {
+			camera.Calibration = calibration
+		})
+		app.StatusLabel.SetText("Calibration applied, save config to keep it")
+	})
+
+	return container.NewVBox(
+		widget.NewLabel("Speed Calibration:"),
+		widthEntry,
+		lengthEntry,
+		container.NewGridWithColumns(2, markBtn, applyBtn),
+	)
+}
+
 func RefreshCanvas(app *App) {
 	app.VideoCanvas.Refresh()
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// bumped on incompatible changes of the message layout
+const wsSchemaVersion = 1
+
+const (
+	WSMessageHello      = "hello"
+	WSMessageDetections = "detections"
+	WSMessageSignState  = "sign_state"
+	WSMessageEvent      = "event"
+)
+
+type WebSocketConfig struct {
+	// detection summaries per second and camera
+	SummaryRate float64 `json:"summaryRate"`
+	// messages queued per client before summaries are dropped
+	ClientBuffer int `json:"clientBuffer"`
+	// browser origins allowed besides the API's own host, "*" allows any
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+/**
+ * Envelope of every message pushed to the clients.
+ */
+type WSMessage struct {
+	Version  int       `json:"version"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	CameraID int       `json:"cameraId"`
+	Data     any       `json:"data"`
+}
+
+type signStateMessage struct {
+	State      string `json:"state"`
+	SpeedLimit int    `json:"speedLimit"`
+}
+
+type wsClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+/**
+ * Fans messages out to the WebSocket subscribers. Every client has
+ * its own queue: detection summaries are dropped for clients that
+ * fall behind, state changes and events are never dropped, a client
+ * whose queue is full for one of them is disconnected instead.
+ */
+type WebSocketHub struct {
+	Config WebSocketConfig
+
+	mu          sync.Mutex
+	clients     map[*wsClient]bool
+	lastSummary map[int]time.Time
+}
+
+func NewWebSocketHub(config WebSocketConfig) *WebSocketHub {
+	if config.ClientBuffer <= 0 {
+		config.ClientBuffer = 32
+	}
+	return &WebSocketHub{
+		Config:      config,
+		clients:     make(map[*wsClient]bool),
+		lastSummary: make(map[int]time.Time),
+	}
+}
+
+/**
+ * Send a message to every client.
+ * @param WSMessage, critical bool false for messages that may be dropped
+ */
+func (h *WebSocketHub) Broadcast(msg WSMessage, critical bool) {
+	msg.Version = wsSchemaVersion
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Printf("Error encoding websocket message: %v\n", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client.send <- data:
+			continue
+		default:
+		}
+
+		if critical {
+			// the client reconnects and gets the current state in the hello message
+			fmt.Printf("Disconnecting websocket client %s, %d messages queued\n", client.conn.Request().RemoteAddr, len(client.send))
+			h.remove(client)
+		}
+	}
+}
+
+/**
+ * Push the detection summary of a camera, at most SummaryRate times a second.
+ * @param *CameraStream
+ */
+func (h *WebSocketHub) PublishDetections(stream *CameraStream) {
+	now := time.Now()
+	h.mu.Lock()
+	if len(h.clients) == 0 {
+		h.mu.Unlock()
+		return
+	}
+	if h.Config.SummaryRate > 0 {
+		interval := time.Duration(float64(time.Second) / h.Config.SummaryRate)
+		if now.Sub(h.lastSummary[stream.Device.ID]) < interval {
+			h.mu.Unlock()
+			return
+		}
+	}
+	h.lastSummary[stream.Device.ID] = now
+	h.mu.Unlock()
+
+	h.Broadcast(WSMessage{Type: WSMessageDetections, Time: now, CameraID: stream.Device.ID, Data: stream.Status()}, false)
+}
+
+func (h *WebSocketHub) remove(client *wsClient) {
+	if h.clients[client] {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+/**
+ * WebSocket endpoint with the origin policy of checkOrigin.
+ * @param *app
+ * @return websocket.Server
+ */
+func (h *WebSocketHub) Server(app *App) websocket.Server {
+	return websocket.Server{Handler: h.Handler(app), Handshake: h.checkOrigin}
+}
+
+/**
+ * Accept clients without an Origin header, such as scripts and
+ * dashboards running outside a browser. Browsers always send one,
+ * it has to be the API's own host or one of AllowedOrigins.
+ * @param *websocket.Config, *http.Request
+ * @return error rejects the handshake with 403
+ */
+func (h *WebSocketHub) checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %v", origin, err)
+	}
+	config.Origin = u
+	if u.Host == r.Host || containsString(h.Config.AllowedOrigins, origin) || containsString(h.Config.AllowedOrigins, "*") {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+/**
+ * Serve one subscriber until it disconnects.
+ * @param *app
+ * @return websocket.Handler
+ */
+func (h *WebSocketHub) Handler(app *App) websocket.Handler {
+	return func(conn *websocket.Conn) {
+		client := &wsClient{conn: conn, send: make(chan []byte, h.Config.ClientBuffer)}
+		h.mu.Lock()
+		h.clients[client] = true
+		h.mu.Unlock()
+
+		// the reader only notices the client going away
+		done := make(chan bool)
+		go func() {
+			var ignored string
+			for websocket.Message.Receive(conn, &ignored) == nil {
+			}
+			close(done)
+		}()
+
+		hello, _ := json.Marshal(WSMessage{
+			Version:  wsSchemaVersion,
+			Type:     WSMessageHello,
+			Time:     time.Now(),
+			CameraID: -1,
+			Data:     app.signStateMessage(),
+		})
+		err := websocket.Message.Send(conn, string(hello))
+
+		for err == nil {
+			select {
+			case data, ok := <-client.send:
+				if !ok {
+					err = fmt.Errorf("client too slow")
+					break
+				}
+				err = websocket.Message.Send(conn, string(data))
+			case <-done:
+				err = fmt.Errorf("client closed")
+			}
+		}
+
+		h.mu.Lock()
+		h.remove(client)
+		h.mu.Unlock()
+		conn.Close()
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		wantErr bool
+	}{
+		{"no origin", nil, "", false},
+		{"same host", nil, "http://sign.local:8080", false},
+		{"other host", nil, "http://evil.example", true},
+		{"same host other port", nil, "http://sign.local:9090", true},
+		{"allowed origin", []string{"https://dashboard.example"}, "https://dashboard.example", false},
+		{"allowed origin other scheme", []string{"https://dashboard.example"}, "http://dashboard.example", true},
+		{"any origin", []string{"*"}, "http://evil.example", false},
+		{"invalid origin", nil, "http://[::1", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hub := NewWebSocketHub(WebSocketConfig{AllowedOrigins: test.allowed})
+			r := httptest.NewRequest("GET", "http://sign.local:8080/api/ws", nil)
+			if test.origin != "" {
+				r.Header.Set("Origin", test.origin)
+			}
+			err := hub.checkOrigin(&websocket.Config{}, r)
+			if (err != nil) != test.wantErr {
+				t.Errorf("checkOrigin(%q) error = %v, want error %v", test.origin, err, test.wantErr)
+			}
+		})
+	}
+}
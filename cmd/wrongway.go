@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type WrongWayConfig struct {
+	// frames in a row a track has to move against its lane direction
+	MinFrames int `json:"minFrames"`
+	// minimum movement over a second to judge the direction, fraction of the frame size
+	MinMovement float32 `json:"minMovement"`
+	// cosine of the angle between motion and lane direction below which the track opposes it
+	MaxCosine float64 `json:"maxCosine"`
+	// seconds the wrong-way warning is kept after the last detection
+	HoldSeconds float64 `json:"holdSeconds"`
+}
+
+/**
+ * Finds tracks that consistently move against the expected
+ * direction of travel of their lane.
+ */
+type WrongWayDetector struct {
+	Config         WrongWayConfig
+	VehicleClasses []string
+
+	opposing map[int]int // consecutive opposing frames per track
+	reported map[int]bool
+	lastSeen time.Time
+}
+
+func NewWrongWayDetector(config WrongWayConfig, vehicleClasses []string) *WrongWayDetector {
+	return &WrongWayDetector{
+		Config:         config,
+		VehicleClasses: vehicleClasses,
+		opposing:       make(map[int]int),
+		reported:       make(map[int]bool),
+	}
+}
+
+/**
+ * Check the motion of every vehicle track against its lane direction.
+ * The first detection of a track raises an EventWrongWay right away.
+ * @param *stream, tracks []*Track, zones []Zone, now time.Time
+ * @return []*Track currently driving the wrong way
+ */
+func (w *WrongWayDetector) Update(stream *CameraStream, tracks []*Track, zones []Zone, now time.Time) []*Track {
+	var wrongWay []*Track
+	seen := make(map[int]bool)
+
+	for _, track := range tracks {
+		if !containsString(w.VehicleClasses, track.ClassName) || track.Lane == "" {
+			continue
+		}
+		lane, ok := findZone(zones, track.Lane)
+		if !ok || lane.Direction == nil {
+			continue
+		}
+		seen[track.ID] = true
+
+		motion, ok := recentMotion(track.History, w.Config.MinMovement)
+		if !ok {
+			continue
+		}
+
+		if cosine(motion, *lane.Direction) > w.Config.MaxCosine {
+			w.opposing[track.ID] = 0
+			continue
+		}
+		w.opposing[track.ID]++
+		if w.opposing[track.ID] < w.Config.MinFrames {
+			continue
+		}
+
+		wrongWay = append(wrongWay, track)
+		w.lastSeen = now
+
+		if !w.reported[track.ID] {
+			w.reported[track.ID] = true
+			stream.queueEvent(Event{
+				Time:    now,
+				Type:    EventWrongWay,
+				TrackID: track.ID,
+				Message: fmt.Sprintf("vehicle #%d driving against the direction of lane %s", track.ID, track.Lane),
+			})
+		}
+	}
+
+	for id := range w.opposing {
+		if !seen[id] {
+			delete(w.opposing, id)
+		}
+	}
+	for id := range w.reported {
+		if !seen[id] {
+			delete(w.reported, id)
+		}
+	}
+
+	return wrongWay
+}
+
+/**
+ * Whether a wrong-way driver was seen within the hold time.
+ * @param now time.Time
+ * @return bool
+ */
+func (w *WrongWayDetector) Active(now time.Time) bool {
+	return !w.lastSeen.IsZero() && now.Sub(w.lastSeen).Seconds() < w.Config.HoldSeconds
+}
+
+/**
+ * Movement of the box bottom center over the last second of history.
+ * @return Point displacement, bool false when the track moved too little
+ */
+func recentMotion(history []TrackPoint, minMovement float32) (Point, bool) {
+	if len(history) < 2 {
+		return Point{}, false
+	}
+	last := history[len(history)-1]
+	first := last
+	for i := len(history) - 2; i >= 0; i-- {
+		if last.Time.Sub(history[i].Time) > time.Second {
+			break
+		}
+		first = history[i]
+	}
+
+	a := normalizePoint(first.BBox.Anchor())
+	b := normalizePoint(last.BBox.Anchor())
+	if distance(a, b) < minMovement {
+		return Point{}, false
+	}
+	return Point{X: b.X - a.X, Y: b.Y - a.Y}, true
+}
+
+func cosine(a, b Point) float64 {
+	la := math.Hypot(float64(a.X), float64(a.Y))
+	lb := math.Hypot(float64(b.X), float64(b.Y))
+	if la == 0 || lb == 0 {
+		return 0
+	}
+	return float64(a.X*b.X+a.Y*b.Y) / (la * lb)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWrongWayDetectorVehicleClasses(t *testing.T) {
+	// traffic on the lane moves down the image
+	lanes := []Zone{{Name: "1", Kind: ZoneLane, Polygon: []Point{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, Direction: &Point{0, 1}}}
+	config := WrongWayConfig{MinFrames: 3, MinMovement: 0.03, MaxCosine: -0.5, HoldSeconds: 60}
+
+	tests := []struct {
+		class string
+		want  bool
+	}{
+		{"car", true},
+		{"bus", true},
+		{"person", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.class, func(t *testing.T) {
+			detector := NewWrongWayDetector(config, []string{"car", "bus"})
+			stream := &CameraStream{}
+			track := &Track{ID: 1, ClassName: test.class, Lane: "1"}
+			start := time.Now()
+
+			var wrongWay []*Track
+			for i := 0; i < 6; i++ {
+				now := start.Add(time.Duration(i) * 100 * time.Millisecond)
+				// moving up against the lane direction
+				y := float32(500 - i*20)
+				track.BBox = BoundingBox{XMin: 300, YMin: y - 40, XMax: 340, YMax: y}
+				track.History = append(track.History, TrackPoint{Time: now, BBox: track.BBox})
+				wrongWay = detector.Update(stream, []*Track{track}, lanes, now)
+			}
+
+			if got := len(wrongWay) == 1; got != test.want {
+				t.Errorf("wrong way = %v, want %v", got, test.want)
+			}
+			if got := detector.Active(start.Add(time.Second)); got != test.want {
+				t.Errorf("warning active = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+	"gocv.io/x/gocv"
+)
+
+type ZoneKind string
+
+const (
+	// detections are only kept inside include zones, if any are defined
+	ZoneInclude ZoneKind = "include"
+	// detections inside exclude zones are dropped, e.g. parking spots
+	ZoneExclude ZoneKind = "exclude"
+	// lanes count as include zones and name the lane of a detection
+	ZoneLane ZoneKind = "lane"
+)
+
+/**
+ * Polygon region of a camera image in normalized frame coordinates.
+ */
+type Zone struct {
+	Name    string   `json:"name"`
+	Kind    ZoneKind `json:"kind"`
+	Polygon []Point  `json:"polygon"`
+	// expected direction of travel on a lane, vector in normalized frame coordinates
+	Direction *Point `json:"direction,omitempty"`
+}
+
+func (z Zone) Contains(p Point) bool {
+	return len(z.Polygon) >= 3 && pointInPolygon(p, z.Polygon)
+}
+
+func (z Zone) Centroid() Point {
+	var c Point
+	for _, p := range z.Polygon {
+		c.X += p.X
+		c.Y += p.Y
+	}
+	n := float32(len(z.Polygon))
+	return Point{X: c.X / n, Y: c.Y / n}
+}
+
+func findZone(zones []Zone, name string) (Zone, bool) {
+	for _, zone := range zones {
+		if zone.Name == name {
+			return zone, true
+		}
+	}
+	return Zone{}, false
+}
+
+func (z Zone) IsRoad() bool {
+	return z.Kind == ZoneInclude || z.Kind == ZoneLane
+}
+
+/**
+ * Whether any include or lane zone marks where the road is.
+ * @param zones []Zone
+ * @return bool
+ */
+func hasRoadZone(zones []Zone) bool {
+	for _, zone := range zones {
+		if zone.IsRoad() {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Whether the track was last seen inside an include or lane zone.
+ * @param track *Track, zones []Zone
+ * @return bool
+ */
+func onRoad(track *Track, zones []Zone) bool {
+	for _, name := range track.Zones {
+		if zone, ok := findZone(zones, name); ok && zone.IsRoad() {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Tag detections with the zones their bottom center falls into and
+ * drop the ones outside include/lane zones or inside exclude zones.
+ * Without include or lane zones the whole frame counts as road.
+ * @param detections []Detection, zones []Zone
+ * @return []Detection
+ */
+func filterDetectionsByZone(detections []Detection, zones []Zone) []Detection {
+	if len(zones) == 0 {
+		return detections
+	}
+
+	hasInclude := hasRoadZone(zones)
+
+	var kept []Detection
+	for _, det := range detections {
+		anchor := normalizePoint(det.BBox.Anchor())
+		included := !hasInclude
+		excluded := false
+		det.Zones = nil
+		det.Lane = ""
+
+		for _, zone := range zones {
+			if !zone.Contains(anchor) {
+				continue
+			}
+			switch zone.Kind {
+			case ZoneExclude:
+				excluded = true
+			case ZoneLane:
+				if det.Lane == "" {
+					det.Lane = zone.Name
+				}
+				included = true
+			case ZoneInclude:
+				included = true
+			}
+			det.Zones = append(det.Zones, zone.Name)
+		}
+
+		if included && !excluded {
+			kept = append(kept, det)
+		}
+	}
+	return kept
+}
+
+var zoneColors = map[ZoneKind]color.RGBA{
+	ZoneInclude: {0, 200, 255, 255},
+	ZoneExclude: {128, 128, 128, 255},
+	ZoneLane:    {255, 0, 255, 255},
+
+	ZoneCalibration: {255, 128, 0, 255},
+}
+
+/**
+ * Draw zone outlines and names onto the frame.
+ * @param *gocv.Mat, zones []Zone
+ */
+func drawZones(mat *gocv.Mat, zones []Zone) {
+	width := float32(mat.Cols())
+	height := float32(mat.Rows())
+
+	for _, zone := range zones {
+		if len(zone.Polygon) == 0 {
+			continue
+		}
+		zoneColor, ok := zoneColors[zone.Kind]
+		if !ok {
+			zoneColor = color.RGBA{255, 255, 255, 255}
+		}
+
+		points := make([]image.Point, len(zone.Polygon))
+		for i, p := range zone.Polygon {
+			points[i] = image.Pt(int(p.X*width), int(p.Y*height))
+			gocv.Circle(mat, points[i], 3, zoneColor, -1)
+		}
+
+		pv := gocv.NewPointsVectorFromPoints([][]image.Point{points})
+		gocv.Polylines(mat, pv, len(points) >= 3, zoneColor, 2)
+		pv.Close()
+
+		gocv.PutText(mat, zone.Name, points[0], gocv.FontHersheySimplex, 0.5, zoneColor, 1)
+
+		if zone.Direction != nil && len(points) >= 3 {
+			c := zone.Centroid()
+			start := image.Pt(int(c.X*width), int(c.Y*height))
+			length := math.Hypot(float64(zone.Direction.X*width), float64(zone.Direction.Y*height))
+			if length > 0 {
+				scale := 40 / length
+				end := image.Pt(start.X+int(float64(zone.Direction.X*width)*scale), start.Y+int(float64(zone.Direction.Y*height)*scale))
+				gocv.ArrowedLine(mat, start, end, zoneColor, 2)
+			}
+		}
+	}
+}
+
+/**
+ * Zones configured for a camera.
+ * @param CameraDevice
+ * @return []Zone
+ */
+func (app *App) cameraZones(device CameraDevice) []Zone {
+	if camera, ok := app.cameraConfig(device); ok {
+		return camera.Zones
+	}
+	return nil
+}
+
+/**
+ * Zones drawn over the video: the configured ones, the calibration
+ * points and, on the focused camera, the polygon being drawn in the Debug tab.
+ * @param CameraDevice, zones []Zone
+ * @return []Zone
+ */
+func (app *App) overlayZones(device CameraDevice, zones []Zone) []Zone {
+	overlay := append([]Zone{}, zones...)
+	if camera, ok := app.cameraConfig(device); ok && camera.Calibration != nil {
+		overlay = append(overlay, Zone{Name: "calibration", Kind: ZoneCalibration, Polygon: camera.Calibration.ImagePoints})
+	}
+	if app.ZoneEditor != nil && app.ZoneEditor.Drawing && device.ID == app.activeCamera() {
+		overlay = append(overlay, app.ZoneEditor.Draft)
+	}
+	return overlay
+}
+
+/**
+ * Transparent widget stacked over VideoCanvas that collects
+ * polygon vertices from clicks while a zone is being drawn.
+ */
+type ZoneEditor struct {
+	widget.BaseWidget
+
+	Drawing bool
+	Draft   Zone
+}
+
+func NewZoneEditor() *ZoneEditor {
+	editor := &ZoneEditor{}
+	editor.ExtendBaseWidget(editor)
+	return editor
+}
+
+func (e *ZoneEditor) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(canvas.NewRectangle(color.Transparent))
+}
+
+func (e *ZoneEditor) Tapped(event *fyne.PointEvent) {
+	size := e.Size()
+	if !e.Drawing || size.Width == 0 || size.Height == 0 {
+		return
+	}
+	e.Draft.Polygon = append(e.Draft.Polygon, Point{
+		X: event.Position.X / size.Width,
+		Y: event.Position.Y / size.Height,
+	})
+}
+
+func (e *ZoneEditor) Start(name string, kind ZoneKind) {
+	e.Drawing = true
+	e.Draft = Zone{Name: name, Kind: kind}
+}
+
+/**
+ * Stop drawing and return the finished zone.
+ * @return Zone, bool false when the polygon has less than three points
+ */
+func (e *ZoneEditor) Finish() (Zone, bool) {
+	zone := e.Draft
+	e.Drawing = false
+	e.Draft = Zone{}
+	return zone, len(zone.Polygon) >= 3
+}